@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	cfg "github.com/sbcinnovation/lmux/internal/config"
+	"github.com/sbcinnovation/lmux/internal/tmux"
+)
+
+func newCompletionCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "completion [bash|zsh|fish|powershell]",
+		Short: "Generate a shell completion script",
+		Long: `Completion prints a script that completes lmux commands, project names and
+running sessions in your shell.
+
+Bash (needs the bash-completion package):
+  lmux completion bash > ~/.local/share/bash-completion/completions/lmux
+
+Zsh (the directory must be in $fpath, before compinit runs):
+  lmux completion zsh > "${fpath[1]}/_lmux"
+
+Fish:
+  lmux completion fish > ~/.config/fish/completions/lmux.fish
+
+PowerShell (add to your profile):
+  lmux completion powershell | Out-String | Invoke-Expression
+
+Start a new shell for the completions to take effect.`,
+		ValidArgs: []string{"bash", "zsh", "fish", "powershell"},
+		Args:      cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			root, out := cmd.Root(), cmd.OutOrStdout()
+			switch args[0] {
+			case "bash":
+				return root.GenBashCompletionV2(out, true)
+			case "zsh":
+				return root.GenZshCompletion(out)
+			case "fish":
+				return root.GenFishCompletion(out, true)
+			default:
+				return root.GenPowerShellCompletionWithDesc(out)
+			}
+		},
+	}
+}
+
+// completeProjects completes a project name argument, noting which projects
+// are running.
+func completeProjects(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return projectCompletions(toComplete, false), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeRunningProjects completes kill's argument with the projects that
+// have a running session, and "all".
+func completeRunningProjects(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	completions := projectCompletions(toComplete, true)
+	if strings.HasPrefix("all", toComplete) {
+		completions = append(completions, "all\tkill every tmux session")
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp
+}
+
+// projectCompletions lists project names starting with prefix, each with its
+// state as the description.
+func projectCompletions(prefix string, onlyRunning bool) []string {
+	names, err := cfg.ListProjects()
+	if err != nil {
+		return nil
+	}
+	running := map[string]bool{}
+	if client, err := newClient("tmux"); err == nil {
+		sessions, _ := tmux.ListSessions(client)
+		for _, s := range sessions {
+			running[s] = true
+		}
+	}
+	var completions []string
+	for _, name := range names {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		sessions := []string{name}
+		if project, err := loadProject(name); err == nil {
+			sessions = []string{project.Name}
+			for _, ps := range profileSessions(project) {
+				sessions = append(sessions, ps.Session)
+			}
+		}
+		var up []string
+		for _, session := range sessions {
+			if running[session] {
+				up = append(up, session)
+			}
+		}
+		switch {
+		case len(up) > 0:
+			completions = append(completions, fmt.Sprintf("%s\trunning as %s", name, strings.Join(up, ", ")))
+		case !onlyRunning:
+			completions = append(completions, name+"\tstopped")
+		}
+	}
+	return completions
+}
+
+// completeTemplates completes the --template flag of init.
+func completeTemplates(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	templates, err := cfg.Templates()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var names []string
+	for _, t := range templates {
+		if strings.HasPrefix(t.Name, toComplete) {
+			names = append(names, t.Name+"\t"+t.Source)
+		}
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+// addProfileFlag adds --profile, completed with the named project's profiles.
+func addProfileFlag(cmd *cobra.Command, profile *string) {
+	cmd.Flags().StringVarP(profile, "profile", "p", "", "use this profile from the project file")
+	_ = cmd.RegisterFlagCompletionFunc("profile", completeProfiles)
+}
+
+// completeProfiles completes --profile with the profiles of the project
+// named by the first argument.
+func completeProfiles(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) == 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	project, err := loadProject(args[0])
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var names []string
+	for _, name := range project.ProfileNames() {
+		if strings.HasPrefix(name, toComplete) {
+			names = append(names, name)
+		}
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeWindows completes --only and --skip with the windows of the project
+// named by the first argument, after any names already given.
+func completeWindows(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	given, prefix := "", toComplete
+	if i := strings.LastIndex(toComplete, ","); i >= 0 {
+		given, prefix = toComplete[:i+1], toComplete[i+1:]
+	}
+	var names []string
+	for _, name := range projectWindows(cmd, args) {
+		if strings.HasPrefix(name, prefix) && !strings.Contains(","+given, ","+name+",") {
+			names = append(names, given+name)
+		}
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveNoSpace
+}
+
+// completeWindow completes --window with a window of the project named by
+// the first argument.
+func completeWindow(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	var names []string
+	for _, name := range projectWindows(cmd, args) {
+		if strings.HasPrefix(name, toComplete) {
+			names = append(names, name)
+		}
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+// projectWindows returns the window names of the project named by the first
+// argument, with the --profile flag's selection.
+func projectWindows(cmd *cobra.Command, args []string) []string {
+	if len(args) == 0 {
+		return nil
+	}
+	profile, _ := cmd.Flags().GetString("profile")
+	project, err := loadProjectProfile(args[0], profile)
+	if err != nil {
+		return nil
+	}
+	names := make([]string, len(project.Windows))
+	for i, w := range project.Windows {
+		names[i] = w.Name
+	}
+	return names
+}
@@ -8,6 +8,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"text/tabwriter"
 
 	"github.com/spf13/cobra"
 
@@ -20,28 +21,49 @@ import (
 var version = buildinfo.Version
 
 func main() {
+	if err := newRootCmd().Execute(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func newRootCmd() *cobra.Command {
 	rootCmd := &cobra.Command{
 		Use:   "lmux",
 		Short: "lmux: simple tmux project runner",
 		Long:  "lmux is a lightweight tmux project runner inspired by tmuxinator.",
 	}
 	rootCmd.SetHelpTemplate(helpTemplate)
+	rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", "table", "output format: table, json or yaml")
+	rootCmd.PersistentFlags().BoolVarP(&assumeYes, "yes", "y", false, "answer yes to confirmations (or set LMUX_ASSUME_YES=1)")
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		return validateOutputFormat()
+	}
 
 	rootCmd.AddCommand(newVersionCmd())
 	rootCmd.AddCommand(newDoctorCmd())
 	rootCmd.AddCommand(newInitCmd())
+	rootCmd.AddCommand(newTemplatesCmd())
 	rootCmd.AddCommand(newEditCmd())
 	rootCmd.AddCommand(newEditorCmd())
+	rootCmd.AddCommand(newConfigCmd())
+	rootCmd.AddCommand(newCopyCmd())
+	rootCmd.AddCommand(newRenameCmd())
+	rootCmd.AddCommand(newDeleteCmd())
 	rootCmd.AddCommand(newListCmd())
+	rootCmd.AddCommand(newStatusCmd())
+	rootCmd.AddCommand(newUICmd())
+	rootCmd.AddCommand(newPopupCmd())
+	rootCmd.AddCommand(newTmuxBindingsCmd())
+	rootCmd.AddCommand(newCompletionCmd())
 	rootCmd.AddCommand(newStartCmd())
+	rootCmd.AddCommand(newDebugCmd())
+	rootCmd.AddCommand(newValidateCmd())
+	rootCmd.AddCommand(newExportCmd())
 	rootCmd.AddCommand(newDetachCmd())
 	rootCmd.AddCommand(newKillCmd())
 	rootCmd.AddCommand(newKillAllCmd())
-
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
-	}
+	return rootCmd
 }
 
 const helpTemplate = `{{with (or .Long .Short)}}{{. | trimTrailingWhitespaces}}
@@ -67,6 +89,17 @@ const helpTemplate = `{{with (or .Long .Short)}}{{. | trimTrailingWhitespaces}}
 Use "{{.CommandPath}} [command] --help" for more information about a command.
 {{end}}`
 
+// versionResult is the structured output of `lmux version`.
+type versionResult struct {
+	Version         string `json:"version" yaml:"version"`
+	Commit          string `json:"commit,omitempty" yaml:"commit,omitempty"`
+	Date            string `json:"date,omitempty" yaml:"date,omitempty"`
+	BuiltBy         string `json:"built_by,omitempty" yaml:"built_by,omitempty"`
+	Latest          string `json:"latest,omitempty" yaml:"latest,omitempty"`
+	UpdateAvailable *bool  `json:"update_available,omitempty" yaml:"update_available,omitempty"`
+	URL             string `json:"url,omitempty" yaml:"url,omitempty"`
+}
+
 func newVersionCmd() *cobra.Command {
 	var check bool
 	var verbose bool
@@ -74,42 +107,55 @@ func newVersionCmd() *cobra.Command {
 		Use:   "version",
 		Short: "Print lmux version",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if verbose {
-				extra := []string{}
-				if strings.TrimSpace(buildinfo.Commit) != "" {
-					extra = append(extra, fmt.Sprintf("commit=%s", buildinfo.Commit))
-				}
-				if strings.TrimSpace(buildinfo.Date) != "" {
-					extra = append(extra, fmt.Sprintf("date=%s", buildinfo.Date))
+			result := versionResult{
+				Version: version,
+				Commit:  strings.TrimSpace(buildinfo.Commit),
+				Date:    strings.TrimSpace(buildinfo.Date),
+				BuiltBy: strings.TrimSpace(buildinfo.BuiltBy),
+			}
+			if check {
+				latest, update, url, err := util.CheckForUpdate("sbcinnovation/lmux", version)
+				if err != nil {
+					return fmt.Errorf("update check failed: %w", err)
 				}
-				if strings.TrimSpace(buildinfo.BuiltBy) != "" {
-					extra = append(extra, fmt.Sprintf("builtBy=%s", buildinfo.BuiltBy))
+				result.Latest = latest
+				result.UpdateAvailable = &update
+				result.URL = strings.TrimSpace(url)
+			}
+
+			return render(cmd.OutOrStdout(), result, func(out io.Writer) error {
+				extra := []string{}
+				if verbose {
+					if result.Commit != "" {
+						extra = append(extra, fmt.Sprintf("commit=%s", result.Commit))
+					}
+					if result.Date != "" {
+						extra = append(extra, fmt.Sprintf("date=%s", result.Date))
+					}
+					if result.BuiltBy != "" {
+						extra = append(extra, fmt.Sprintf("builtBy=%s", result.BuiltBy))
+					}
 				}
 				if len(extra) > 0 {
-					fmt.Printf("%s (%s)\n", version, strings.Join(extra, ", "))
+					fmt.Fprintf(out, "%s (%s)\n", version, strings.Join(extra, ", "))
 				} else {
-					fmt.Println(version)
+					fmt.Fprintln(out, version)
 				}
-			} else {
-				fmt.Println(version)
-			}
 
-			if check {
-				latest, update, url, err := util.CheckForUpdate("sbcinnovation/lmux", version)
-				if err != nil {
-					return fmt.Errorf("update check failed: %w", err)
+				if result.UpdateAvailable == nil {
+					return nil
 				}
-				if update {
-					if strings.TrimSpace(url) == "" {
-						fmt.Printf("update available: %s -> %s\n", version, latest)
+				if *result.UpdateAvailable {
+					if result.URL == "" {
+						fmt.Fprintf(out, "update available: %s -> %s\n", version, result.Latest)
 					} else {
-						fmt.Printf("update available: %s -> %s\n%s\n", version, latest, url)
+						fmt.Fprintf(out, "update available: %s -> %s\n%s\n", version, result.Latest, result.URL)
 					}
 				} else {
-					fmt.Println("you're up to date")
+					fmt.Fprintln(out, "you're up to date")
 				}
-			}
-			return nil
+				return nil
+			})
 		},
 	}
 	cmd.Flags().BoolVar(&check, "check", false, "check for newer release on GitHub")
@@ -117,27 +163,59 @@ func newVersionCmd() *cobra.Command {
 	return cmd
 }
 
+// doctorCheck is one environment check reported by `lmux doctor`.
+type doctorCheck struct {
+	Name   string `json:"name" yaml:"name"`
+	OK     bool   `json:"ok" yaml:"ok"`
+	Detail string `json:"detail" yaml:"detail"`
+}
+
 func newDoctorCmd() *cobra.Command {
 	return &cobra.Command{
 		Use:   "doctor",
 		Short: "Check environment for lmux usage",
 		RunE: func(cmd *cobra.Command, args []string) error {
+			var checks []doctorCheck
+
 			// Check config dir
-			dir, err := cfg.EnsureConfigDir()
-			if err != nil {
-				return err
+			if dir, err := cfg.EnsureConfigDir(); err != nil {
+				checks = append(checks, doctorCheck{Name: "config dir", Detail: err.Error()})
+			} else {
+				checks = append(checks, doctorCheck{Name: "config dir", OK: true, Detail: dir})
 			}
-			fmt.Printf("config dir: %s\n", dir)
 
 			// Check tmux presence and version
 			if err := tmux.CheckTmuxInstalled(); err != nil {
+				checks = append(checks, doctorCheck{Name: "tmux", Detail: err.Error()})
+			} else if ver, err := tmux.TmuxVersion(); err != nil {
+				checks = append(checks, doctorCheck{Name: "tmux version", Detail: err.Error()})
+			} else {
+				checks = append(checks, doctorCheck{Name: "tmux version", OK: true, Detail: ver})
+			}
+
+			var failed []string
+			for _, c := range checks {
+				if !c.OK {
+					failed = append(failed, fmt.Sprintf("%s: %s", c.Name, c.Detail))
+				}
+			}
+			err := render(cmd.OutOrStdout(), checks, func(out io.Writer) error {
+				for _, c := range checks {
+					if c.OK {
+						fmt.Fprintf(out, "%s: %s\n", c.Name, c.Detail)
+					}
+				}
+				if len(failed) == 0 {
+					fmt.Fprintln(out, "doctor: OK")
+				}
+				return nil
+			})
+			if err != nil {
 				return err
 			}
-			ver, _ := tmux.TmuxVersion()
-			if ver != "" {
-				fmt.Printf("tmux version: %s\n", ver)
+			if len(failed) > 0 {
+				return errors.New(strings.Join(failed, "; "))
 			}
-			fmt.Println("doctor: OK")
 			return nil
 		},
 	}
@@ -145,22 +223,69 @@ func newDoctorCmd() *cobra.Command {
 
 func newInitCmd() *cobra.Command {
 	var force bool
+	var template, from string
 	cmd := &cobra.Command{
 		Use:   "init [name]",
 		Short: "Create a new project TOML in ~/.config/lmux",
-		Args:  cobra.ExactArgs(1),
+		Long: `Init creates a project file from a template. Without --template, the
+template is picked from files in the current directory (go.mod, package.json,
+pyproject.toml, bin/rails, docker-compose.yml), and package.json scripts and
+Procfile entries become windows. See lmux templates for what is available.
+
+With --from procfile or --from compose, the project instead points at the
+Procfile or compose file in the current directory, and its windows follow
+that file each time the project starts.`,
+		Example: `  lmux init myapp
+  lmux init myapp --template go
+  lmux init myapp --from compose`,
+		Args: cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			name := sanitizeName(args[0])
 			if name == "" {
 				return errors.New("invalid project name")
 			}
-			// Fill template hints
 			wd, _ := os.Getwd()
-			path, err := cfg.SaveSample(name, wd, force)
+			var path, origin string
+			var err error
+			switch {
+			case from != "":
+				if template != "" {
+					return errors.New("--from and --template cannot be combined")
+				}
+				names := map[string][]string{"procfile": cfg.ProcfileNames, "compose": cfg.ComposeFileNames}[from]
+				if names == nil {
+					return fmt.Errorf("unsupported --from %q (supported: procfile, compose)", from)
+				}
+				file, err := cfg.FindProcessFile(wd, names)
+				if err != nil {
+					return err
+				}
+				origin = " with windows from " + file
+				path, err = cfg.SaveProject(name, cfg.GeneratedProject(name, wd, from, file), force)
+				if err != nil {
+					return err
+				}
+			default:
+				detected := cfg.DetectProject(wd)
+				if template == "" {
+					template = detected.Template
+				}
+				if template == "" {
+					// Fill template hints
+					path, err = cfg.SaveSample(name, wd, force)
+					break
+				}
+				var t cfg.Template
+				if t, err = cfg.LoadTemplate(template); err != nil {
+					return err
+				}
+				origin = " from the " + template + " template"
+				path, err = cfg.SaveProject(name, cfg.RenderTemplate(t.Content, name, wd, detected.Windows), force)
+			}
 			if err != nil {
 				return err
 			}
-			fmt.Printf("created %s\n", path)
+			fmt.Printf("created %s%s\n", path, origin)
 
 			// Open in editor if possible
 			if err := util.OpenInEditor(path); err != nil {
@@ -170,17 +295,66 @@ func newInitCmd() *cobra.Command {
 		},
 	}
 	cmd.Flags().BoolVarP(&force, "force", "f", false, "overwrite if file exists")
+	cmd.Flags().StringVarP(&template, "template", "t", "", "template to start from (default: detected from the current directory)")
+	_ = cmd.RegisterFlagCompletionFunc("template", completeTemplates)
+	cmd.Flags().StringVar(&from, "from", "", "generate windows from the current directory's procfile or compose file")
+	_ = cmd.RegisterFlagCompletionFunc("from", cobra.FixedCompletions([]string{"procfile", "compose"}, cobra.ShellCompDirectiveNoFileComp))
 	return cmd
 }
 
+// templateEntry is one template reported by `lmux templates`.
+type templateEntry struct {
+	Name   string `json:"name" yaml:"name"`
+	Source string `json:"source" yaml:"source"`
+}
+
+func newTemplatesCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "templates",
+		Short: "List templates for lmux init",
+		Long:  "Templates lists the built-in templates and those in ~/.config/lmux/templates, which replace built-ins of the same name.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			templates, err := cfg.Templates()
+			if err != nil {
+				return err
+			}
+			entries := make([]templateEntry, len(templates))
+			for i, t := range templates {
+				entries[i] = templateEntry{Name: t.Name, Source: t.Source}
+			}
+			return render(cmd.OutOrStdout(), entries, func(out io.Writer) error {
+				w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+				for _, e := range entries {
+					fmt.Fprintf(w, "%s\t%s\n", e.Name, e.Source)
+				}
+				return w.Flush()
+			})
+		},
+	}
+}
+
 func newEditCmd() *cobra.Command {
 	var editorFlag string
+	var copyFlag bool
 	cmd := &cobra.Command{
 		Use:   "edit [name]",
 		Short: "Open an existing project TOML in editor",
-		Args:  cobra.ExactArgs(1),
+		Long: `Edit opens ~/.config/lmux/<name>.toml in your editor. Without a name in a
+terminal, pick the project interactively.
+
+When the editor exits, lmux checks that the project still loads and, if not,
+shows the error and offers to re-open the editor. With --copy you edit a
+temporary copy instead, and the project file is only replaced once the copy
+loads.`,
+		Args:              cobra.MaximumNArgs(1),
+		ValidArgsFunction: completeProjects,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			name := sanitizeName(args[0])
+			arg, err := projectArg(args, "edit> ", errors.New("missing project name"))
+			if err != nil {
+				return err
+			}
+			name := sanitizeName(arg)
 			if name == "" {
 				return errors.New("invalid project name")
 			}
@@ -191,141 +365,419 @@ func newEditCmd() *cobra.Command {
 
 			// If --editor is provided, persist it immediately
 			if strings.TrimSpace(editorFlag) != "" {
-				settings, _ := cfg.LoadSettings()
+				settings, err := cfg.LoadSettings()
+				if err != nil {
+					return err
+				}
 				settings.Editor = strings.TrimSpace(editorFlag)
 				if err := cfg.SaveSettings(settings); err != nil {
 					fmt.Fprintf(os.Stderr, "warning: failed to save editor setting: %v\n", err)
 				}
 			}
-			return util.OpenInEditor(path)
+			return editProject(path, copyFlag)
 		},
 	}
 	cmd.Flags().StringVar(&editorFlag, "editor", "", "set and persist the editor to use (e.g. 'nvim', 'code -w')")
+	cmd.Flags().BoolVar(&copyFlag, "copy", false, "edit a temporary copy and replace the project file only once it loads")
 	return cmd
 }
 
+// editProject opens the project file at path in the editor until it loads
+// or the user gives up, like visudo. With useCopy the edits go to a
+// temporary copy, so the project file never holds an invalid project.
+func editProject(path string, useCopy bool) error {
+	editPath := path
+	if useCopy {
+		tmp, err := tempProjectCopy(path)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(tmp)
+		editPath = tmp
+	}
+	line := 0
+	for {
+		if err := util.OpenInEditorAt(editPath, line); err != nil {
+			return err
+		}
+		_, loadErr := cfg.LoadProjectFile(editPath)
+		if loadErr == nil {
+			break
+		}
+		// Re-open at the offending line when the error has one
+		line = cfg.ErrorLine(loadErr)
+		if line > 0 {
+			fmt.Fprintf(os.Stderr, "%s:%d: %v\n", path, line, loadErr)
+		} else {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, loadErr)
+		}
+		if !stdinIsTerminal() {
+			return invalidEditError(path, useCopy, loadErr)
+		}
+		again, err := ask("Re-open the editor?")
+		if err != nil {
+			return err
+		}
+		if !again {
+			return invalidEditError(path, useCopy, loadErr)
+		}
+	}
+	if !useCopy {
+		return nil
+	}
+	data, err := os.ReadFile(editPath)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// tempProjectCopy copies the project file at path to a temporary file with
+// the same extension, so editors still recognise it as TOML.
+func tempProjectCopy(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp("", "lmux-*-"+filepath.Base(path))
+	if err != nil {
+		return "", err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), tmp.Close()
+}
+
+func invalidEditError(path string, useCopy bool, err error) error {
+	if useCopy {
+		return fmt.Errorf("discarded invalid changes; %s is unchanged: %w", path, err)
+	}
+	return fmt.Errorf("%s is invalid: %w", path, err)
+}
+
+// projectEntry is one project reported by `lmux list`.
+type projectEntry struct {
+	Name    string `json:"name" yaml:"name"`
+	Path    string `json:"path" yaml:"path"`
+	Running bool   `json:"running" yaml:"running"`
+}
+
 func newListCmd() *cobra.Command {
 	return &cobra.Command{
 		Use:     "list",
 		Aliases: []string{"ls"},
 		Short:   "List projects in ~/.config/lmux",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			dir, err := cfg.EnsureConfigDir()
+			names, err := cfg.ListProjects()
 			if err != nil {
 				return err
 			}
-			entries, err := os.ReadDir(dir)
-			if err != nil {
-				return err
-			}
-			for _, e := range entries {
-				if e.IsDir() {
-					continue
+			entries := make([]projectEntry, 0, len(names))
+			if structuredOutput() {
+				// Running state needs tmux; without it every project is reported stopped
+				running := map[string]bool{}
+				if client, err := newClient("tmux"); err == nil {
+					sessions, _ := tmux.ListSessions(client)
+					for _, s := range sessions {
+						running[s] = true
+					}
 				}
-				name := e.Name()
-				if strings.HasSuffix(name, ".toml") {
-					fmt.Println(strings.TrimSuffix(name, ".toml"))
+				for _, name := range names {
+					session := name
+					if project, err := loadProject(name); err == nil {
+						session = project.Name
+					}
+					entries = append(entries, projectEntry{Name: name, Path: cfg.ProjectFilePath(name), Running: running[session]})
 				}
 			}
-			return nil
+			return render(cmd.OutOrStdout(), entries, func(out io.Writer) error {
+				for _, name := range names {
+					fmt.Fprintln(out, name)
+				}
+				return nil
+			})
 		},
 	}
 }
 
 func newStartCmd() *cobra.Command {
 	var attach bool
-	var rootOverride string
+	var rootOverride, profile, workspace, primary, window string
+	var only, skip []string
+	var jobs int
 	cmd := &cobra.Command{
-		Use:   "start [name]",
+		Use:   "start [name...]",
 		Short: "Start a tmux session for the project",
 		Long: `Start loads ~/.config/lmux/<name>.toml and creates or attaches to that session.
 
-The project name is always required. Use --root only to override the "root" path from the config for this run.`,
-		Example: `  lmux start myapp --root ~/dev/sbc/sbc-nextchess`,
+Without a name in a terminal, pick a project or running session interactively. Use --root only to override the "root" path from the config for this run.
+
+With several names, or --workspace and a file in ~/.config/lmux/workspaces/,
+start sets up the projects' sessions concurrently, reports how each went and
+attaches to the primary project: --primary, the workspace's primary, or the
+first one. It does not attach if any project failed.`,
+		Example: `  lmux start myapp --root ~/dev/sbc/sbc-nextchess
+  lmux start myapp --profile light
+  lmux start myapp --only editor,tests
+  lmux start myapp --skip logs
+  lmux start myapp --window tests
+  lmux start api web infra --primary web
+  lmux start --workspace day`,
+		ValidArgsFunction: completeStartProjects,
 		Args: func(cmd *cobra.Command, args []string) error {
-			if len(args) == 0 {
-				return fmt.Errorf("missing project name (the TOML in ~/.config/lmux/<name>.toml); example: %s myapp --root ~/path", cmd.CommandPath())
+			if workspace != "" && len(args) > 0 {
+				return errors.New("--workspace takes no project names; list them in the workspace file")
 			}
-			if len(args) > 1 {
-				return fmt.Errorf("expected one project name, got %d extra arguments", len(args)-1)
+			if len(args) == 0 && workspace == "" && !isTerminal() {
+				return fmt.Errorf("missing project name (the TOML in ~/.config/lmux/<name>.toml); example: %s myapp --root ~/path", cmd.CommandPath())
 			}
 			return nil
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			name := sanitizeName(args[0])
-			if name == "" {
-				return errors.New("invalid project name")
+			if workspace != "" || len(args) > 1 {
+				for _, flag := range []string{"root", "profile", "only", "skip", "window"} {
+					if cmd.Flags().Changed(flag) {
+						return fmt.Errorf("--%s applies to one project; set it per project in a workspace file", flag)
+					}
+				}
+				ws, err := startWorkspace(workspace, args)
+				if err != nil {
+					return err
+				}
+				if primary != "" {
+					if !workspaceHas(ws, primary) {
+						return fmt.Errorf("--primary %s is not one of the projects started", primary)
+					}
+					ws.Primary = primary
+				}
+				if cmd.Flags().Changed("jobs") {
+					if jobs < 1 {
+						return fmt.Errorf("--jobs must be at least 1, got %d", jobs)
+					}
+					ws.Jobs = jobs
+				}
+				var attachOverride *bool
+				if cmd.Flags().Changed("attach") {
+					attachOverride = &attach
+				}
+				return startMany(cmd, ws, attachOverride)
+			}
+
+			var target pickTarget
+			if len(args) > 0 {
+				target.Project = args[0]
+			} else {
+				var err error
+				if target, err = pickProject("start> ", true); err != nil {
+					return err
+				}
+			}
+			if target.Session != "" {
+				client, err := newClient("tmux")
+				if err != nil {
+					return err
+				}
+				return tmux.AttachSession(client, target.Session)
 			}
-			project, err := cfg.LoadProject(name)
+
+			project, err := loadProjectProfile(target.Project, profile)
 			if err != nil {
 				return err
 			}
-			if project.Name == "" {
-				project.Name = name
-			}
 			if cmd.Flags().Changed("root") {
 				project.Root = cfg.ExpandPath(rootOverride)
 			}
+			if err := project.FilterWindows(only, skip); err != nil {
+				return err
+			}
+			if window != "" {
+				if err := project.SetStartupWindow(window); err != nil {
+					return err
+				}
+				// A running session is only attached, so select the window now
+				if client, err := projectClient(project); err == nil && tmux.HasSession(client, project.Name) {
+					if err := client.Run("select-window", "-t", "="+project.Name+":"+window); err != nil {
+						return err
+					}
+				}
+			}
 
 			// Use the config value unless the flag explicitly overrides it.
 			if !cmd.Flags().Changed("attach") {
 				attach = *project.Attach
 			}
 
-			return tmux.StartProject(project, attach)
+			return startProject(project, attach)
 		},
 	}
 	cmd.Flags().BoolVar(&attach, "attach", true, "attach to the session after starting")
 	cmd.Flags().StringVarP(&rootOverride, "root", "C", "", "override the project root directory from the config")
+	addProfileFlag(cmd, &profile)
+	cmd.Flags().StringSliceVar(&only, "only", nil, "start only these windows (comma-separated)")
+	cmd.Flags().StringSliceVar(&skip, "skip", nil, "do not start these windows (comma-separated)")
+	_ = cmd.RegisterFlagCompletionFunc("only", completeWindows)
+	_ = cmd.RegisterFlagCompletionFunc("skip", completeWindows)
+	cmd.Flags().StringVar(&window, "window", "", "select this window instead of startup_window")
+	_ = cmd.RegisterFlagCompletionFunc("window", completeWindow)
+	cmd.Flags().StringVarP(&workspace, "workspace", "w", "", "start the projects of this workspace")
+	cmd.Flags().StringVar(&primary, "primary", "", "attach to this project when starting several")
+	cmd.Flags().IntVarP(&jobs, "jobs", "j", cfg.DefaultWorkspaceJobs, "how many projects to set up at once when starting several")
+	_ = cmd.RegisterFlagCompletionFunc("workspace", completeWorkspaces)
+	_ = cmd.RegisterFlagCompletionFunc("primary", completePrimary)
 	return cmd
 }
 
-func newDetachCmd() *cobra.Command {
-	return &cobra.Command{
-		Use:     "detach",
-		Aliases: []string{"d"},
-		Short:   "Detach the current tmux client",
+func newDebugCmd() *cobra.Command {
+	var profile string
+	cmd := &cobra.Command{
+		Use:               "debug [name]",
+		Short:             "Print the tmux commands start would run for a project",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeProjects,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return tmux.DetachClient()
+			project, err := loadProjectProfile(args[0], profile)
+			if err != nil {
+				return err
+			}
+			for _, c := range tmux.Plan(project) {
+				fmt.Fprintln(cmd.OutOrStdout(), tmux.FormatCommand(project.TmuxCommand, project.TmuxFlags, c))
+			}
+			return nil
 		},
 	}
+	addProfileFlag(cmd, &profile)
+	return cmd
 }
 
-func newKillCmd() *cobra.Command {
-	return &cobra.Command{
-		Use:     "kill [name|all]",
-		Aliases: []string{"k"},
-		Short:   "Kill a project's tmux session or all sessions",
-		Args:    cobra.ExactArgs(1),
+func newExportCmd() *cobra.Command {
+	var format, file, profile string
+	cmd := &cobra.Command{
+		Use:               "export [name]",
+		Short:             "Export a project as a standalone script",
+		Example:           `  lmux export myapp --format sh > myapp.sh`,
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeProjects,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			name := sanitizeName(args[0])
-			if name == "" {
-				return errors.New("invalid project name")
+			if format != "sh" {
+				return fmt.Errorf("unsupported export format %q (supported: sh)", format)
 			}
-			if name == "all" {
-				return killAllSessions()
+			project, err := loadProjectProfile(args[0], profile)
+			if err != nil {
+				return err
 			}
-			project, err := cfg.LoadProject(name)
+			script, err := tmux.Script(project)
 			if err != nil {
 				return err
 			}
-			if project.Name == "" {
-				project.Name = name
+			if file == "" {
+				fmt.Fprint(cmd.OutOrStdout(), script)
+				return nil
+			}
+			if err := os.WriteFile(file, []byte(script), 0o755); err != nil {
+				return err
 			}
+			fmt.Fprintf(cmd.OutOrStdout(), "exported %s\n", file)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&format, "format", "sh", "export format (sh)")
+	cmd.Flags().StringVarP(&file, "file", "f", "", "write to file instead of stdout")
+	addProfileFlag(cmd, &profile)
+	return cmd
+}
 
-			confirmed, err := confirm(fmt.Sprintf("Kill tmux session for %q?", project.Name))
+func newDetachCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "detach",
+		Aliases: []string{"d"},
+		Short:   "Detach the current tmux client",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := newClient("tmux")
 			if err != nil {
 				return err
 			}
-			if !confirmed {
-				return nil
+			return tmux.DetachClient(client)
+		},
+	}
+}
+
+// killResult is the structured output of `lmux kill` and `lmux kill-all`.
+type killResult struct {
+	// Killed is the session killed, or "server" when all sessions were.
+	Killed  string   `json:"killed" yaml:"killed"`
+	Aborted bool     `json:"aborted,omitempty" yaml:"aborted,omitempty"`
+	Active  []string `json:"active" yaml:"active"`
+}
+
+func newKillCmd() *cobra.Command {
+	var profile string
+	cmd := &cobra.Command{
+		Use:               "kill [name|all]",
+		Aliases:           []string{"k"},
+		Short:             "Kill a project's tmux session or all sessions",
+		Long:              "Kill stops the tmux session of the named project, or every session with \"all\". Without a name in a terminal, pick the project or session interactively.",
+		Args:              cobra.MaximumNArgs(1),
+		ValidArgsFunction: completeRunningProjects,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var session string
+			if len(args) > 0 {
+				name := sanitizeName(args[0])
+				if name == "" {
+					return errors.New("invalid project name")
+				}
+				if name == "all" {
+					return killAllSessions(cmd.OutOrStdout())
+				}
+				project, err := loadProjectProfile(name, profile)
+				if err != nil {
+					return err
+				}
+				session = project.Name
+			} else {
+				if !isTerminal() {
+					return errors.New("missing project name")
+				}
+				target, err := pickProject("kill> ", true)
+				if err != nil {
+					return err
+				}
+				session = target.Session
+				if target.Project != "" {
+					project, err := loadProjectProfile(target.Project, profile)
+					if err != nil {
+						return err
+					}
+					session = project.Name
+				}
 			}
-			if err := tmux.KillSession(project.Name); err != nil {
+
+			result := killResult{Killed: session}
+			confirmed, err := confirm(fmt.Sprintf("Kill tmux session for %q?", session))
+			if err != nil {
 				return err
 			}
-			return showActiveProjects()
+			if confirmed {
+				client, err := newClient("tmux")
+				if err != nil {
+					return err
+				}
+				if err := tmux.KillSession(client, session); err != nil {
+					return err
+				}
+				if result.Active, err = tmux.ListSessions(client); err != nil {
+					return err
+				}
+			} else {
+				result.Aborted = true
+			}
+			return renderKill(cmd.OutOrStdout(), result)
 		},
 	}
+	addProfileFlag(cmd, &profile)
+	return cmd
 }
 
 func newKillAllCmd() *cobra.Command {
@@ -335,67 +787,221 @@ func newKillAllCmd() *cobra.Command {
 		Short:   "Kill all tmux sessions",
 		Args:    cobra.NoArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return killAllSessions()
+			return killAllSessions(cmd.OutOrStdout())
 		},
 	}
 }
 
-func killAllSessions() error {
-	confirmed, err := confirm("Kill tmux server and all sessions?")
+// newClient returns the tmux client commands talk to; tests swap in a tmux.Fake.
+var newClient = func(bin string) (tmux.Client, error) {
+	client, err := tmux.NewExecClient(bin)
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// projectClient returns the client for a project's session, which passes
+// the project's tmux_options to every command.
+func projectClient(project cfg.Project) (tmux.Client, error) {
+	client, err := newClient(project.TmuxCommand)
+	if err != nil {
+		return nil, err
+	}
+	if execClient, ok := client.(*tmux.ExecClient); ok {
+		execClient.Flags = project.TmuxFlags
+	}
+	return client, nil
+}
+
+// buildClient returns the client sessions are built with: a control-mode
+// backend when the tmux_backend setting asks for one.
+func buildClient(project cfg.Project) (tmux.Client, error) {
+	client, err := projectClient(project)
+	if err != nil {
+		return nil, err
+	}
+	settings, err := cfg.LoadSettings()
+	if err != nil {
+		return nil, err
+	}
+	if execClient, ok := client.(*tmux.ExecClient); ok && settings.TmuxBackend == "control" {
+		return tmux.NewControlBackend(execClient, nil), nil
+	}
+	return client, nil
+}
+
+// startProject builds the project's session and optionally attaches,
+// closing any control connection once done.
+func startProject(project cfg.Project, attach bool) error {
+	client, err := buildClient(project)
 	if err != nil {
 		return err
 	}
-	if !confirmed {
-		return nil
+	if closer, ok := client.(io.Closer); ok {
+		defer closer.Close()
 	}
-	if err := tmux.KillServer(); err != nil {
+	return tmux.StartProject(client, project, attach)
+}
+
+func killAllSessions(out io.Writer) error {
+	result := killResult{Killed: "server"}
+	confirmed, err := confirm("Kill tmux server and all sessions?")
+	if err != nil {
 		return err
 	}
-	return showActiveProjects()
+	if confirmed {
+		client, err := newClient("tmux")
+		if err != nil {
+			return err
+		}
+		if err := tmux.KillServer(client); err != nil {
+			return err
+		}
+		if result.Active, err = tmux.ListSessions(client); err != nil {
+			return err
+		}
+	} else {
+		result.Aborted = true
+	}
+	return renderKill(out, result)
 }
 
+// assumeYes is set by the global --yes flag.
+var assumeYes bool
+
+// confirm asks a yes/no question on stdin. --yes or LMUX_ASSUME_YES answers
+// it up front; without either, a stdin that is not a terminal is an error
+// rather than a silent "no", so scripts fail loudly.
 func confirm(prompt string) (bool, error) {
+	if assumeYes || envEnabled("LMUX_ASSUME_YES") {
+		return true, nil
+	}
+	return ask(prompt)
+}
+
+// ask is confirm without the --yes shortcut, for questions a blanket yes
+// should not answer.
+func ask(prompt string) (bool, error) {
+	if !stdinIsTerminal() {
+		return false, fmt.Errorf("cannot ask %q without a terminal; pass --yes or set LMUX_ASSUME_YES=1", prompt)
+	}
+	// Keep prompts out of structured output so it stays parseable
+	promptOut := os.Stdout
+	if structuredOutput() {
+		promptOut = os.Stderr
+	}
 	reader := bufio.NewReader(os.Stdin)
-	fmt.Fprintf(os.Stdout, "%s [y/N]: ", prompt)
+	fmt.Fprintf(promptOut, "%s [y/N]: ", prompt)
 	input, err := reader.ReadString('\n')
 	if err != nil && !errors.Is(err, io.EOF) {
 		return false, fmt.Errorf("confirmation failed: %w", err)
 	}
 	resp := strings.ToLower(strings.TrimSpace(input))
 	if resp != "y" && resp != "yes" {
-		fmt.Fprintln(os.Stdout, "aborted")
 		return false, nil
 	}
 	return true, nil
 }
 
-func showActiveProjects() error {
-	sessions, err := tmux.ListSessions()
-	if err != nil {
-		return err
+// envEnabled reports whether the environment variable is set to a true value
+// such as 1, true or yes.
+func envEnabled(name string) bool {
+	switch strings.ToLower(strings.TrimSpace(os.Getenv(name))) {
+	case "1", "true", "yes", "y", "on":
+		return true
 	}
-	if len(sessions) == 0 {
-		fmt.Fprintln(os.Stdout, "No active projects loaded.")
+	return false
+}
+
+// renderKill reports the outcome of a kill along with the sessions still running.
+func renderKill(out io.Writer, result killResult) error {
+	if result.Active == nil {
+		result.Active = []string{}
+	}
+	return render(out, result, func(out io.Writer) error {
+		if result.Aborted {
+			fmt.Fprintln(out, "aborted")
+			return nil
+		}
+		if len(result.Active) == 0 {
+			fmt.Fprintln(out, "No active projects loaded.")
+			return nil
+		}
+
+		fmt.Fprintln(out, "Active projects loaded:")
+		for _, session := range result.Active {
+			fmt.Fprintf(out, "- %s\n", session)
+		}
 		return nil
+	})
+}
+
+// loadProject loads the named project, defaulting its session name to the project name.
+func loadProject(arg string) (cfg.Project, error) {
+	return loadProjectProfile(arg, "")
+}
+
+// loadProjectProfile is loadProject with the named profile, if any.
+func loadProjectProfile(arg, profile string) (cfg.Project, error) {
+	name := sanitizeName(arg)
+	if name == "" {
+		return cfg.Project{}, errors.New("invalid project name")
 	}
+	project, err := cfg.LoadProjectProfile(name, profile)
+	if err != nil {
+		return project, err
+	}
+	if project.Name == "" {
+		project.Name = name
+	}
+	return project, nil
+}
 
-	fmt.Fprintln(os.Stdout, "Active projects loaded:")
-	for _, session := range sessions {
-		fmt.Fprintf(os.Stdout, "- %s\n", session)
+// profileSession is the session of a project profile with a session_suffix.
+type profileSession struct {
+	Profile string
+	Session string
+}
+
+// profileSessions lists the sessions the project's suffixed profiles start,
+// by profile name. Profiles without a suffix share the project's session.
+func profileSessions(project cfg.Project) []profileSession {
+	var sessions []profileSession
+	for _, name := range project.ProfileNames() {
+		if suffix := project.Profiles[name].SessionSuffix; suffix != "" {
+			sessions = append(sessions, profileSession{Profile: name, Session: project.Name + suffix})
+		}
 	}
-	return nil
+	return sessions
 }
 
+// sanitizeName turns an argument into a project name, or "" if it is not
+// one. Names may be nested, like "work/api", but not leave the project
+// directory.
 func sanitizeName(name string) string {
 	name = strings.TrimSpace(name)
 	name = strings.TrimSuffix(name, filepath.Ext(name))
 	name = strings.ReplaceAll(name, " ", "-")
-	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+	if name == "" || strings.Contains(name, `\`) {
 		return ""
 	}
+	for _, part := range strings.Split(name, "/") {
+		if part == "" || part == "." || part == ".." {
+			return ""
+		}
+	}
 	return name
 }
 
+// editorResult is the structured output of `lmux editor`.
+type editorResult struct {
+	Editor string `json:"editor" yaml:"editor"`
+	// Source is where the editor came from: "settings", "env", "default" or
+	// "" if none was found.
+	Source string `json:"source" yaml:"source"`
+}
+
 // newEditorCmd provides `lmux editor` to get or set the editor.
 // Usage:
 //
@@ -406,31 +1012,51 @@ func newEditorCmd() *cobra.Command {
 	return &cobra.Command{
 		Use:   "editor [command]",
 		Short: "Get or set the editor used by lmux",
-		Args:  cobra.MaximumNArgs(1),
+		Long: `Editor prints or saves the editor lmux opens project files with, like
+lmux config get/set editor. Without a saved editor lmux uses $VISUAL, then
+$EDITOR, then open -t on macOS or xdg-open, sensible-editor or vi elsewhere.
+
+The command is split into words like a shell would, so quote paths with
+spaces. {file} and {line} in it are replaced by the file and the line to jump
+to, e.g. "nvim +{line}" or "code -w -g {file}:{line}"; words with {line} are
+left out when there is no line.`,
+		Args: cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if len(args) == 0 {
-				settings, _ := cfg.LoadSettings()
-				current := strings.TrimSpace(settings.Editor)
-				if current == "" {
-					current = strings.TrimSpace(os.Getenv("EDITOR"))
+				var result editorResult
+				if editor, err := util.ResolveEditor(); err == nil {
+					result = editorResult{Editor: editor.Command, Source: editor.Source}
 				}
-				if current == "" {
-					fmt.Println("(no editor configured)")
+				return render(cmd.OutOrStdout(), result, func(out io.Writer) error {
+					if result.Editor == "" {
+						fmt.Fprintln(out, "(no editor configured)")
+						return nil
+					}
+					fmt.Fprintln(out, result.Editor)
 					return nil
-				}
-				fmt.Println(current)
-				return nil
+				})
 			}
 
 			// Set editor
 			ed := strings.TrimSpace(args[0])
-			settings, _ := cfg.LoadSettings()
+			if ed != "" {
+				if _, err := (util.Editor{Command: ed}).Args("", 0); err != nil {
+					return err
+				}
+			}
+			settings, err := cfg.LoadSettings()
+			if err != nil {
+				return err
+			}
 			settings.Editor = ed
 			if err := cfg.SaveSettings(settings); err != nil {
 				return err
 			}
-			fmt.Printf("editor set to: %s\n", ed)
-			return nil
+			result := editorResult{Editor: ed, Source: "settings"}
+			return render(cmd.OutOrStdout(), result, func(out io.Writer) error {
+				fmt.Fprintf(out, "editor set to: %s\n", ed)
+				return nil
+			})
 		},
 	}
 }
@@ -1,11 +1,23 @@
 package main
 
 import (
+	"encoding/json"
+	"fmt"
 	"io"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	cfg "github.com/sbcinnovation/lmux/internal/config"
+	"github.com/sbcinnovation/lmux/internal/picker"
+	"github.com/sbcinnovation/lmux/internal/tmux"
+	"github.com/sbcinnovation/lmux/internal/tui"
 )
 
 func TestKillCmdKillsOnlyProjectSession(t *testing.T) {
@@ -58,6 +70,7 @@ printf '%s\n' "$@" >> "$LMUX_TMUX_ARGS"
 	originalStdin := os.Stdin
 	os.Stdin = input
 	defer func() { os.Stdin = originalStdin }()
+	useStdinTerminal(t, true)
 
 	cmd := newKillCmd()
 	cmd.SetArgs([]string{"project"})
@@ -70,7 +83,7 @@ printf '%s\n' "$@" >> "$LMUX_TMUX_ARGS"
 	if err != nil {
 		t.Fatal(err)
 	}
-	if got, want := string(args), "kill-session\n-t\nproject-session\n"; got != want {
+	if got, want := string(args), "kill-session\n-t\n=project-session\n"; got != want {
 		t.Fatalf("tmux arguments = %q, want %q", strings.TrimSpace(got), strings.TrimSpace(want))
 	}
 	if !strings.Contains(output, "Active projects loaded:\n- remaining-project\n") {
@@ -111,6 +124,7 @@ printf '%s\n' "$@" > "$LMUX_TMUX_ARGS"
 	originalStdin := os.Stdin
 	os.Stdin = input
 	defer func() { os.Stdin = originalStdin }()
+	useStdinTerminal(t, true)
 
 	cmd := newKillAllCmd()
 	output, err := captureStdout(t, cmd.Execute)
@@ -194,9 +208,877 @@ app = "true"
 }
 
 func TestSanitizeNameRejectsPathTraversal(t *testing.T) {
-	for _, name := range []string{"../outside", `..\outside`, ".", "..", ""} {
+	for _, name := range []string{"../outside", `..\outside`, ".", "..", "", "work/../../outside", "/etc/passwd", "work//api"} {
 		if got := sanitizeName(name); got != "" {
 			t.Errorf("sanitizeName(%q) = %q, want empty", name, got)
 		}
 	}
+	if got := sanitizeName("work/api.toml"); got != "work/api" {
+		t.Errorf("sanitizeName(work/api.toml) = %q, want the nested name", got)
+	}
+}
+
+func TestStartCmdBuildsSessionThroughClient(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("TMUX", "")
+	writeProject(t, home, "project", `name = "project-session"
+attach = false
+
+[[windows]]
+app = "make run"
+`)
+	fake := useFakeClient(t)
+
+	cmd := newStartCmd()
+	cmd.SetArgs([]string{"project"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Join(fake.Commands(), "\n")
+	if !strings.Contains(got, "new-session -d -s project-session -n app") {
+		t.Fatalf("commands = %q, want new-session for the project", got)
+	}
+}
+
+func TestWhenConditionsApplyWhenTheProjectLoads(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("TMUX", "")
+	writeProject(t, home, "api", `root = "`+home+`"
+attach = false
+
+[[windows]]
+server = "go run ."
+
+[[windows]]
+db = { commands = "docker compose up db", when = { command = "test -e services.on" } }
+`)
+	fake := useFakeClient(t)
+	windows := func() string {
+		got, _ := completeWindow(newStartCmd(), []string{"api"}, "")
+		return strings.Join(got, ",")
+	}
+
+	if got := windows(); got != "server" {
+		t.Fatalf("completed windows = %q, want db left out", got)
+	}
+	out, err := runRoot(t, "debug", "api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out, "-n db") {
+		t.Fatalf("debug output = %q, want db left out", out)
+	}
+
+	if err := os.WriteFile(filepath.Join(home, "services.on"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := windows(); got != "server,db" {
+		t.Fatalf("completed windows = %q, want db kept", got)
+	}
+	if _, err := runRoot(t, "start", "api"); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(fake.Commands(), "\n"); !strings.Contains(got, "-n db ") {
+		t.Fatalf("commands = %q, want the db window kept", got)
+	}
+}
+
+// runRoot runs the root command with args and returns what it wrote to
+// stdout.
+func runRoot(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	cmd := newRootCmd()
+	var out strings.Builder
+	cmd.SetOut(&out)
+	cmd.SetErr(io.Discard)
+	cmd.SetArgs(args)
+	err := cmd.Execute()
+	return out.String(), err
+}
+
+func writeProject(t *testing.T, home, name, content string) {
+	t.Helper()
+	path := filepath.Join(home, ".config", "lmux", name+".toml")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func useFakeClient(t *testing.T, sessions ...string) *tmux.Fake {
+	t.Helper()
+	fake := tmux.NewFake(sessions...)
+	original := newClient
+	newClient = func(string) (tmux.Client, error) { return fake, nil }
+	t.Cleanup(func() { newClient = original })
+	return fake
+}
+
+func TestStatusCmdMatchesProjectsToSessions(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	writeProject(t, home, "api", "name = \"api-dev\"\n\n[[windows]]\nserver = \"go run .\"\n")
+	writeProject(t, home, "web", "[[windows]]\napp = \"npm start\"\n")
+	fake := useFakeClient(t)
+	fake.Outputs = map[string]string{
+		"list-sessions": "1 2 1700000000 api-dev\n0 1 1700000000 scratch pad\n",
+		"list-panes":    "0 0 go server\n1 0 nvim my editor\n",
+	}
+
+	cmd := newStatusCmd()
+	cmd.SetArgs([]string{"--json"})
+	var out strings.Builder
+	cmd.SetOut(&out)
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	var statuses []projectStatus
+	if err := json.Unmarshal([]byte(out.String()), &statuses); err != nil {
+		t.Fatalf("invalid JSON %q: %v", out.String(), err)
+	}
+	if len(statuses) != 3 {
+		t.Fatalf("got %d statuses, want api, web and the unmanaged session: %+v", len(statuses), statuses)
+	}
+	api, web, scratch := statuses[0], statuses[1], statuses[2]
+	if !api.Running || api.Session != "api-dev" || api.Attached != 1 || api.Windows != 2 {
+		t.Errorf("api status = %+v, want running api-dev with 1 client and 2 windows", api)
+	}
+	if len(api.Panes) != 2 || api.Panes[1].Window != "my editor" || api.Panes[1].Command != "nvim" {
+		t.Errorf("api panes = %+v, want nvim running in window %q", api.Panes, "my editor")
+	}
+	if web.Running || web.Project != "web" {
+		t.Errorf("web status = %+v, want stopped", web)
+	}
+	if scratch.Project != "" || scratch.Session != "scratch pad" || !scratch.Running {
+		t.Errorf("unmanaged status = %+v, want running session without project", scratch)
+	}
+}
+
+func TestListCmdRendersProjectsAsJSON(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	writeProject(t, home, "api", "name = \"api-dev\"\n\n[[windows]]\nserver = \"go run .\"\n")
+	writeProject(t, home, "web", "[[windows]]\napp = \"npm start\"\n")
+	useFakeClient(t, "api-dev")
+	useOutputFormat(t, "json")
+
+	cmd := newListCmd()
+	var out strings.Builder
+	cmd.SetOut(&out)
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	var entries []projectEntry
+	if err := json.Unmarshal([]byte(out.String()), &entries); err != nil {
+		t.Fatalf("invalid JSON %q: %v", out.String(), err)
+	}
+	if len(entries) != 2 || !entries[0].Running || entries[1].Running {
+		t.Fatalf("entries = %+v, want api running and web stopped", entries)
+	}
+	if want := filepath.Join(home, ".config", "lmux", "web.toml"); entries[1].Path != want {
+		t.Fatalf("web path = %q, want %q", entries[1].Path, want)
+	}
+}
+
+func TestRenderYAMLUsesFieldNames(t *testing.T) {
+	useOutputFormat(t, "yaml")
+	var out strings.Builder
+	if err := render(&out, doctorCheck{Name: "tmux version", OK: true, Detail: "tmux 3.4"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := out.String(), "name: tmux version\nok: true\ndetail: tmux 3.4\n"; got != want {
+		t.Fatalf("yaml = %q, want %q", got, want)
+	}
+}
+
+func TestRenderRejectsUnknownFormat(t *testing.T) {
+	useOutputFormat(t, "xml")
+	if err := render(io.Discard, nil, nil); err == nil {
+		t.Fatal("render accepted an unknown output format")
+	}
+}
+
+func useOutputFormat(t *testing.T, format string) {
+	t.Helper()
+	original := outputFormat
+	outputFormat = format
+	t.Cleanup(func() { outputFormat = original })
+}
+
+func TestStartCmdPicksProjectWhenNoNameGiven(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("TMUX", "")
+	writeProject(t, home, "api", "name = \"api-dev\"\nattach = false\n\n[[windows]]\nserver = \"go run .\"\n")
+	writeProject(t, home, "web", "attach = false\n\n[[windows]]\napp = \"npm start\"\n")
+	fake := useFakeClient(t, "api-dev", "scratch")
+
+	var items []picker.Item
+	useTerminal(t, true, func(prompt string, offered []picker.Item) (string, error) {
+		items = offered
+		return offered[1].Value, nil
+	})
+
+	cmd := newStartCmd()
+	cmd.SetArgs([]string{})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+
+	var labels []string
+	for _, it := range items {
+		labels = append(labels, fmt.Sprintf("%s:%v", it.Label, it.Running))
+	}
+	if got, want := strings.Join(labels, " "), "api:true web:false scratch:true"; got != want {
+		t.Fatalf("picker items = %q, want %q", got, want)
+	}
+	if preview := strings.Join(items[0].Preview(), "\n"); !strings.Contains(preview, "1 server  go run .") {
+		t.Fatalf("preview = %q, want window list", preview)
+	}
+	if got := strings.Join(fake.Commands(), "\n"); !strings.Contains(got, "new-session -d -s web -n app") {
+		t.Fatalf("commands = %q, want the picked project started", got)
+	}
+}
+
+func TestStartCmdRequiresNameOutsideTerminal(t *testing.T) {
+	useTerminal(t, false, func(string, []picker.Item) (string, error) {
+		t.Fatal("picker shown without a terminal")
+		return "", nil
+	})
+	cmd := newStartCmd()
+	cmd.SetArgs([]string{})
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "missing project name") {
+		t.Fatalf("err = %v, want missing project name", err)
+	}
+}
+
+func useTerminal(t *testing.T, interactive bool, pick func(string, []picker.Item) (string, error)) {
+	t.Helper()
+	originalTerminal, originalPicker := isTerminal, runPicker
+	isTerminal = func() bool { return interactive }
+	runPicker = pick
+	t.Cleanup(func() { isTerminal, runPicker = originalTerminal, originalPicker })
+}
+
+func TestDashboardShowsSessionsAndRunsActions(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("TMUX", "")
+	writeProject(t, home, "api", "name = \"api-dev\"\n\n[[windows]]\nserver = \"go run .\"\n")
+	writeProject(t, home, "web", "[[windows]]\napp = \"npm start\"\n")
+	fake := useFakeClient(t, "api-dev")
+	fake.Outputs = map[string]string{
+		"list-sessions": "1 1 1700000000 api-dev\n",
+		"list-panes":    "0 0 go server\n",
+		"capture-pane":  "listening on :8080\nGET /health 200\n\n\n",
+	}
+
+	d := newDashboard(fake)
+	d.now = func() time.Time { return time.Unix(1700000300, 0) }
+	d.reload()
+	view := strings.Join(func() []string { lines, _, _ := d.view(100, 30); return lines }(), "\n")
+	for _, want := range []string{"api-dev", "running", "5m", "0:server.0  go", "GET /health 200"} {
+		if !strings.Contains(view, want) {
+			t.Fatalf("view missing %q:\n%s", want, view)
+		}
+	}
+
+	// Start the stopped project
+	d.handleKey(tui.Key{Name: tui.KeyDown})
+	d.handleKey(tui.Key{Rune: 's'})
+	if got := strings.Join(fake.Commands(), "\n"); !strings.Contains(got, "new-session -d -s web -n app") {
+		t.Fatalf("commands = %q, want web started", got)
+	}
+
+	// Stopping asks first
+	d.handleKey(tui.Key{Name: tui.KeyUp})
+	d.handleKey(tui.Key{Rune: 'x'})
+	if !strings.Contains(d.message, `Stop session "api-dev"?`) {
+		t.Fatalf("message = %q, want stop confirmation", d.message)
+	}
+	d.handleKey(tui.Key{Rune: 'y'})
+	if got := strings.Join(fake.Commands(), "\n"); !strings.Contains(got, "kill-session -t =api-dev") {
+		t.Fatalf("commands = %q, want api-dev killed", got)
+	}
+
+	// Deleting a project removes its file once confirmed
+	d.handleKey(tui.Key{Name: tui.KeyDown})
+	d.handleKey(tui.Key{Rune: 'd'})
+	d.handleKey(tui.Key{Rune: 'y'})
+	if _, err := os.Stat(filepath.Join(home, ".config", "lmux", "web.toml")); !os.IsNotExist(err) {
+		t.Fatalf("web.toml still exists: %v", err)
+	}
+
+	if quit := d.handleKey(tui.Key{Rune: 'q'}); !quit {
+		t.Fatal("q did not quit the dashboard")
+	}
+}
+
+func TestPopupCmdOpensPickerInPopup(t *testing.T) {
+	t.Setenv("TMUX", "/tmp/tmux-0/default,1,0")
+	fake := useFakeClient(t)
+	fake.Outputs = map[string]string{"display-message": "/dev/pts/3\n"}
+	original := executable
+	executable = func() (string, error) { return "/usr/local/bin/lmux", nil }
+	t.Cleanup(func() { executable = original })
+
+	cmd := newPopupCmd()
+	cmd.SetArgs([]string{})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	got := fake.Commands()
+	want := "display-popup -E -c /dev/pts/3 -w 60% -h 60% -T  lmux  /usr/local/bin/lmux popup --in-popup --client /dev/pts/3"
+	if len(got) != 2 || got[1] != want {
+		t.Fatalf("commands = %q, want %q", got, want)
+	}
+}
+
+func TestPopupCmdSwitchesClientToPickedProject(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("TMUX", "/tmp/tmux-0/default,1,0")
+	writeProject(t, home, "api", "name = \"api-dev\"\n\n[[windows]]\nserver = \"go run .\"\n")
+	fake := useFakeClient(t)
+	useTerminal(t, true, func(string, []picker.Item) (string, error) { return "project:api", nil })
+
+	cmd := newPopupCmd()
+	cmd.SetArgs([]string{"--in-popup", "--client", "/dev/pts/3"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Join(fake.Commands(), "\n")
+	if !strings.Contains(got, "new-session -d -s api-dev") || !strings.HasSuffix(got, "switch-client -c /dev/pts/3 -t =api-dev") {
+		t.Fatalf("commands = %q, want api-dev started and switched to", got)
+	}
+}
+
+func TestTmuxBindingsCmdUsesKeys(t *testing.T) {
+	cmd := newTmuxBindingsCmd()
+	var out strings.Builder
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"--popup-key", "C-j"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`bind-key C-j run-shell "lmux popup"`, `bind-key U display-popup -E -w 90% -h 90% -T " lmux " "lmux ui"`} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("output = %q, want %q", out.String(), want)
+		}
+	}
+}
+
+func TestCompletionListsProjectsAndRunningState(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	writeProject(t, home, "api", "name = \"api-dev\"\n\n[[windows]]\nserver = \"go run .\"\n")
+	writeProject(t, home, "web", "[[windows]]\napp = \"npm start\"\n")
+	writeProject(t, home, "worker", "[[windows]]\nq = \"make work\"\n")
+	useFakeClient(t, "api-dev")
+
+	got, directive := completeProjects(nil, nil, "w")
+	if want := "web\tstopped,worker\tstopped"; strings.Join(got, ",") != want || directive != cobra.ShellCompDirectiveNoFileComp {
+		t.Fatalf("completeProjects = %q (%v), want %q", got, directive, want)
+	}
+	if got, _ := completeProjects(nil, []string{"web"}, ""); len(got) != 0 {
+		t.Fatalf("completed a second argument: %q", got)
+	}
+	got, _ = completeRunningProjects(nil, nil, "")
+	if want := "api\trunning as api-dev,all\tkill every tmux session"; strings.Join(got, ",") != want {
+		t.Fatalf("completeRunningProjects = %q, want %q", got, want)
+	}
+}
+
+func TestCompletionListsNestedProjectsAndWindows(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	writeProject(t, home, "work/api", "[[windows]]\nserver = \"go run .\"\n\n[[windows]]\nshell = \"\"\n")
+	writeProject(t, home, "workspaces/day", "[[projects]]\nname = \"work/api\"\n")
+	useFakeClient(t)
+
+	got, _ := completeProjects(nil, nil, "wo")
+	if want := "work/api\tstopped"; strings.Join(got, ",") != want {
+		t.Fatalf("completeProjects = %q, want %q", got, want)
+	}
+	cmd := newStartCmd()
+	got, _ = completeWindow(cmd, []string{"work/api"}, "s")
+	if want := "server,shell"; strings.Join(got, ",") != want {
+		t.Fatalf("completeWindow = %q, want %q", got, want)
+	}
+}
+
+func TestStartCmdSelectsWindow(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("TMUX", "")
+	writeProject(t, home, "work/api", "attach = false\nstartup_window = \"server\"\n\n[[windows]]\nserver = \"go run .\"\n\n[[windows]]\ntests = \"go test ./...\"\n")
+	fake := useFakeClient(t)
+
+	if _, err := runRoot(t, "start", "work/api", "--window", "tests"); err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Join(fake.Commands(), "\n")
+	if !strings.Contains(got, "new-session -d -s work/api") || !strings.HasSuffix(got, "select-window -t work/api:tests") {
+		t.Fatalf("commands = %q, want work/api built with tests selected", got)
+	}
+
+	fake = useFakeClient(t, "work/api")
+	if _, err := runRoot(t, "start", "work/api", "--window", "tests"); err != nil {
+		t.Fatal(err)
+	}
+	if got := fake.Commands(); !slices.Contains(got, "select-window -t =work/api:tests") {
+		t.Fatalf("commands = %q, want tests selected in the running session", got)
+	}
+	if _, err := runRoot(t, "start", "work/api", "--window", "test"); err == nil || !strings.Contains(err.Error(), `did you mean "tests"`) {
+		t.Fatalf("start --window test: err = %v, want a suggestion", err)
+	}
+}
+
+func TestRenameCmdRenamesRunningSession(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	writeProject(t, home, "api", "name = \"api\"\n\n[[windows]]\nserver = \"go run .\"\n")
+	fake := useFakeClient(t, "api")
+
+	cmd := newRenameCmd()
+	cmd.SetOut(io.Discard)
+	cmd.SetArgs([]string{"api", "backend"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if got := fake.Commands(); got[len(got)-1] != "rename-session -t =api backend" {
+		t.Fatalf("commands = %q, want rename-session", got)
+	}
+	if _, err := os.Stat(filepath.Join(home, ".config", "lmux", "backend.toml")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRenameCmdRenamesProfileSessions(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	writeProject(t, home, "api", "[[windows]]\nserver = \"go run .\"\n\n[profiles.light]\nsession_suffix = \"-light\"\n\n[profiles.full]\nsession_suffix = \"-full\"\n")
+	fake := useFakeClient(t, "api-light")
+
+	out, err := runRoot(t, "rename", "api", "backend")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var renames []string
+	for _, c := range fake.Commands() {
+		if strings.HasPrefix(c, "rename-session") {
+			renames = append(renames, c)
+		}
+	}
+	if want := "rename-session -t =api-light backend-light"; strings.Join(renames, "\n") != want {
+		t.Fatalf("renames = %q, want only %q", renames, want)
+	}
+	if !strings.Contains(out, "renamed session api-light to backend-light") {
+		t.Fatalf("output = %q, want the profile session reported", out)
+	}
+}
+
+func TestRenameCmdIgnoresSessionSharingPrefix(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	writeProject(t, home, "api", "[[windows]]\nserver = \"go run .\"\n")
+	fake := useFakeClient(t, "api-dev")
+
+	cmd := newRenameCmd()
+	cmd.SetOut(io.Discard)
+	cmd.SetArgs([]string{"api", "backend"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range fake.Commands() {
+		if strings.HasPrefix(c, "rename-session") {
+			t.Fatalf("commands = %q, want api-dev left alone", fake.Commands())
+		}
+	}
+}
+
+func TestRenameAndDeleteKeepToTheSearchPath(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	shared := filepath.Join(home, "shared")
+	if err := os.MkdirAll(shared, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.SaveSettings(cfg.Settings{SearchPaths: []string{shared}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(shared, "api.toml"), []byte("[[windows]]\nserver = \"go run .\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	useFakeClient(t)
+
+	cmd := newRenameCmd()
+	var out strings.Builder
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"api", "backend"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(shared, "backend.toml")
+	if !strings.HasSuffix(strings.TrimSpace(out.String()), " to "+path) {
+		t.Fatalf("output = %q, want the project renamed within %s", out.String(), shared)
+	}
+	if got, want := describeProjectFile(path), path+" (in a search path)"; got != want {
+		t.Fatalf("describeProjectFile() = %q, want %q", got, want)
+	}
+	own := filepath.Join(home, ".config", "lmux", "web.toml")
+	if got := describeProjectFile(own); got != own {
+		t.Fatalf("describeProjectFile() = %q, want %q", got, own)
+	}
+}
+
+func TestDeleteCmdSkipsConfirmationWithYes(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	writeProject(t, home, "api", "[[windows]]\nserver = \"go run .\"\n")
+
+	useStdinTerminal(t, false)
+	t.Cleanup(func() { assumeYes = false })
+
+	out, err := runRoot(t, "delete", "api", "--yes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(home, ".config", "lmux", "api.toml")); !os.IsNotExist(err) {
+		t.Fatalf("api.toml still exists: %v", err)
+	}
+	if !strings.HasPrefix(out, "deleted ") {
+		t.Fatalf("output = %q", out)
+	}
+}
+
+func TestConfirmRefusesWithoutTerminal(t *testing.T) {
+	useStdinTerminal(t, false)
+	t.Setenv("LMUX_ASSUME_YES", "")
+	if _, err := confirm("Kill tmux server and all sessions?"); err == nil || !strings.Contains(err.Error(), "--yes") {
+		t.Fatalf("confirm without a terminal: err = %v, want a hint about --yes", err)
+	}
+
+	t.Setenv("LMUX_ASSUME_YES", "true")
+	if ok, err := confirm("Kill tmux server and all sessions?"); err != nil || !ok {
+		t.Fatalf("confirm with LMUX_ASSUME_YES = %v, %v, want yes", ok, err)
+	}
+}
+
+func useStdinTerminal(t *testing.T, interactive bool) {
+	t.Helper()
+	original := stdinIsTerminal
+	stdinIsTerminal = func() bool { return interactive }
+	t.Cleanup(func() { stdinIsTerminal = original })
+}
+
+// useEditorScript makes $EDITOR a script that overwrites the edited file with
+// each of edits in turn, repeating the last one.
+func useEditorScript(t *testing.T, home string, edits ...string) {
+	t.Helper()
+	var script strings.Builder
+	script.WriteString("#!/bin/sh\ncount=$(cat \"$LMUX_EDIT_COUNT\" 2>/dev/null || echo 0)\necho $((count + 1)) > \"$LMUX_EDIT_COUNT\"\ncase $count in\n")
+	for i, edit := range edits {
+		pattern := fmt.Sprint(i)
+		if i == len(edits)-1 {
+			pattern = "*"
+		}
+		fmt.Fprintf(&script, "%s) printf '%%s' '%s' > \"$1\" ;;\n", pattern, edit)
+	}
+	script.WriteString("esac\n")
+	path := filepath.Join(home, "editor")
+	if err := os.WriteFile(path, []byte(script.String()), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("VISUAL", "")
+	t.Setenv("EDITOR", path)
+	t.Setenv("LMUX_EDIT_COUNT", filepath.Join(home, "edit-count"))
+}
+
+func TestEditProjectReopensUntilValid(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	writeProject(t, home, "api", "name = \"api\"\n\n[[windows]]\nshell = \"\"\n")
+	useEditorScript(t, home, "name = [\n", "name = \"api-dev\"\n\n[[windows]]\nshell = \"\"\n")
+
+	input, err := os.CreateTemp(home, "input")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer input.Close()
+	if _, err := input.WriteString("y\n"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := input.Seek(0, 0); err != nil {
+		t.Fatal(err)
+	}
+	originalStdin := os.Stdin
+	os.Stdin = input
+	defer func() { os.Stdin = originalStdin }()
+	useStdinTerminal(t, true)
+
+	path := filepath.Join(home, ".config", "lmux", "api.toml")
+	if _, err := captureStdout(t, func() error { return editProject(path, false) }); err != nil {
+		t.Fatal(err)
+	}
+	count, _ := os.ReadFile(filepath.Join(home, "edit-count"))
+	if got := strings.TrimSpace(string(count)); got != "2" {
+		t.Fatalf("editor ran %s times, want 2", got)
+	}
+	project, err := loadProject("api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if project.Name != "api-dev" {
+		t.Fatalf("project name = %q, want api-dev", project.Name)
+	}
+}
+
+func TestEditProjectCopyKeepsFileWhenInvalid(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	writeProject(t, home, "api", "name = \"api\"\n\n[[windows]]\nshell = \"\"\n")
+	useEditorScript(t, home, "name = [\n")
+	useStdinTerminal(t, false)
+
+	path := filepath.Join(home, ".config", "lmux", "api.toml")
+	err := editProject(path, true)
+	if err == nil || !strings.Contains(err.Error(), "unchanged") {
+		t.Fatalf("editProject error = %v, want invalid changes discarded", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "name = \"api\"\n\n[[windows]]\nshell = \"\"\n" {
+		t.Fatalf("project file = %q, want it untouched", data)
+	}
+}
+
+func TestConfigCmdSetsAndGetsSettings(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	if _, err := runRoot(t, "config", "set", "search_paths", "~/a", "~/b"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runRoot(t, "config", "set", "attach", "maybe"); err == nil {
+		t.Fatal("expected attach to reject a non-boolean")
+	}
+	if _, err := runRoot(t, "config", "set", "layout", "tiled", "even-horizontal"); err == nil {
+		t.Fatal("expected layout to reject several values")
+	}
+	out, err := runRoot(t, "config", "get", "search_paths")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "~/a\n~/b\n" {
+		t.Fatalf("get search_paths = %q", out)
+	}
+	if _, err := runRoot(t, "config", "unset", "search_paths"); err != nil {
+		t.Fatal(err)
+	}
+	if out, _ := runRoot(t, "config", "get", "search_paths"); out != "" {
+		t.Fatalf("get after unset = %q, want nothing", out)
+	}
+}
+
+func TestValidateCmdChecksVariant(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	writeProject(t, home, "api", "[[windows]]\nserver = \"go run .\"\n\n[overrides.\"host:ci\"]\nwindows = [ { broken = 1 } ]\n")
+
+	out, err := runRoot(t, "validate", "--host", "laptop")
+	if err != nil || !strings.HasPrefix(out, "ok") {
+		t.Fatalf("validate = %q, %v; want ok", out, err)
+	}
+	out, err = runRoot(t, "validate", "api", "--host", "ci")
+	if err == nil || !strings.Contains(out, "invalid") || !strings.Contains(out, "unsupported window value") {
+		t.Fatalf("validate --host ci = %q, %v; want the override's error", out, err)
+	}
+}
+
+func TestStartCmdSkipsFirstWindow(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("TMUX", "")
+	writeProject(t, home, "project", "attach = false\nstartup_window = \"logs\"\n\n[[windows]]\nlogs = \"tail -f log\"\n\n[[windows]]\neditor = \"nvim\"\n\n[[windows]]\ntests = \"go test ./...\"\n")
+	fake := useFakeClient(t)
+
+	cmd := newStartCmd()
+	cmd.SetArgs([]string{"project", "--skip", "logs"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Join(fake.Commands(), "\n")
+	if !strings.Contains(got, "new-session -d -s project -n editor") || strings.Contains(got, "logs") {
+		t.Fatalf("commands = %q, want the session to open on editor without logs", got)
+	}
+
+	cmd = newStartCmd()
+	cmd.SetArgs([]string{"project", "--only", "editr,tests"})
+	cmd.SetErr(io.Discard)
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), `did you mean "editor"?`) {
+		t.Fatalf("start --only editr error = %v, want a suggestion", err)
+	}
+}
+
+func TestStartCmdStartsSeveralProjectsAndAttachesPrimary(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("TMUX", "")
+	writeProject(t, home, "api", "name = \"api-dev\"\n\n[[windows]]\nserver = \"go run .\"\n")
+	writeProject(t, home, "web", "[[windows]]\napp = \"npm start\"\n")
+	writeProject(t, home, "infra", "[[windows]]\nlogs = \"kubectl logs -f\"\n\n[profiles.light]\nwindows = [\"logs\"]\n")
+	fake := useFakeClient(t, "web")
+
+	var out strings.Builder
+	cmd := newStartCmd()
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"api", "web", "infra", "--primary", "web"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"started  api  as api-dev", "running  web", "started  infra"} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("output = %q, want a line %q", out.String(), want)
+		}
+	}
+	got := fake.Commands()
+	if got[len(got)-1] != "attach-session -t =web" {
+		t.Fatalf("last command = %q, want web attached", got[len(got)-1])
+	}
+
+	if err := os.MkdirAll(filepath.Join(home, ".config", "lmux", "workspaces"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	day := "[[projects]]\nname = \"infra\"\nprofile = \"heavy\"\n\n[[projects]]\nname = \"api\"\n"
+	if err := os.WriteFile(filepath.Join(home, ".config", "lmux", "workspaces", "day.toml"), []byte(day), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fake = useFakeClient(t)
+	out.Reset()
+	cmd = newStartCmd()
+	cmd.SetOut(&out)
+	cmd.SetErr(io.Discard)
+	cmd.SetArgs([]string{"--workspace", "day"})
+	if err := cmd.Execute(); err == nil || err.Error() != "1 of 2 projects failed to start" {
+		t.Fatalf("start --workspace error = %v, want one failure reported", err)
+	}
+	if !strings.Contains(out.String(), `failed   infra  unknown profile "heavy"`) {
+		t.Fatalf("output = %q, want the infra failure", out.String())
+	}
+	for _, c := range fake.Commands() {
+		if strings.HasPrefix(c, "attach-session") {
+			t.Fatalf("commands = %q, want no attach after a failure", fake.Commands())
+		}
+	}
+}
+
+func TestStartCmdReportsProjectWhoseNamePrefixesRunningSession(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("TMUX", "")
+	writeProject(t, home, "api", "attach = false\n\n[[windows]]\nserver = \"go run .\"\n")
+	writeProject(t, home, "api-dev", "attach = false\n\n[[windows]]\nserver = \"go run . -dev\"\n")
+	fake := useFakeClient(t, "api-dev")
+
+	out, err := runRoot(t, "start", "api", "api-dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"started  api\n", "running  api-dev\n"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("output = %q, want a line %q", out, want)
+		}
+	}
+	if got := strings.Join(fake.Commands(), "\n"); !strings.Contains(got, "new-session -d -s api ") {
+		t.Fatalf("commands = %q, want a session built for api", got)
+	}
+}
+
+func TestBuildClientUsesControlBackendSetting(t *testing.T) {
+	if _, err := exec.LookPath("tmux"); err != nil {
+		t.Skip("tmux is not installed")
+	}
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	flags := []string{"-L", "work"}
+	if client, err := buildClient(cfg.Project{TmuxCommand: "tmux", TmuxFlags: flags}); err != nil {
+		t.Fatal(err)
+	} else if execClient, ok := client.(*tmux.ExecClient); !ok {
+		t.Fatalf("buildClient() = %T, want an ExecClient by default", client)
+	} else if !slices.Equal(execClient.Flags, flags) {
+		t.Fatalf("client flags = %q, want the project's tmux_options %q", execClient.Flags, flags)
+	}
+	if err := cfg.SaveSettings(cfg.Settings{TmuxBackend: "control"}); err != nil {
+		t.Fatal(err)
+	}
+	client, err := buildClient(cfg.Project{TmuxCommand: "tmux"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := client.(*tmux.ControlBackend); !ok {
+		t.Fatalf("buildClient() = %T, want a ControlBackend with tmux_backend = control", client)
+	}
+}
+
+func TestProfileSessionIsNotMistakenForProject(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("TMUX", "")
+	writeProject(t, home, "app", "attach = false\n\n[[windows]]\neditor = \"nvim\"\n\n[profiles.light]\nsession_suffix = \"-light\"\n")
+	fake := useFakeClient(t, "app-light")
+
+	cmd := newStartCmd()
+	cmd.SetArgs([]string{"app"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(fake.Commands(), "\n"); !strings.Contains(got, "new-session -d -s app -n editor") {
+		t.Fatalf("commands = %q, want app created beside app-light", got)
+	}
+
+	fake = useFakeClient(t)
+	fake.Outputs = map[string]string{"list-sessions": "0 1 1700000000 app-light\n"}
+	statuses, err := collectStatus(fake, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 2 || statuses[1].Project != "app" || statuses[1].Profile != "light" || !statuses[1].Running {
+		t.Fatalf("statuses = %+v, want app stopped and its light profile running", statuses)
+	}
+}
+
+func TestEditorCmdKeepsUnreadableSettings(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	path := filepath.Join(home, ".config", "lmux", "settings.toml")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	broken := "tmux_command = \"tmux\"\nedit_in = \n"
+	if err := os.WriteFile(path, []byte(broken), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	writeProject(t, home, "api", "[[windows]]\nshell = \"\"\n")
+
+	for _, args := range [][]string{{"editor", "vi"}, {"edit", "api", "--editor", "vi"}} {
+		if _, err := runRoot(t, args...); err == nil {
+			t.Fatalf("%v: err = nil, want the settings parse error", args)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != broken {
+			t.Fatalf("%v: settings = %q, want them left as they were", args, data)
+		}
+	}
 }
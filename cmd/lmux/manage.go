@@ -0,0 +1,156 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	cfg "github.com/sbcinnovation/lmux/internal/config"
+	"github.com/sbcinnovation/lmux/internal/tmux"
+)
+
+func newCopyCmd() *cobra.Command {
+	var force bool
+	cmd := &cobra.Command{
+		Use:               "copy <src> <dst>",
+		Aliases:           []string{"cp"},
+		Short:             "Copy a project to a new name",
+		Long:              "Copy duplicates a project file. The copy's name field is set to the new name so both can run at once.",
+		Args:              cobra.ExactArgs(2),
+		ValidArgsFunction: completeProjects,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			src, dst, err := projectNames(args[0], args[1])
+			if err != nil {
+				return err
+			}
+			path, err := cfg.CopyProject(src, dst, force)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "created %s\n", path)
+			return nil
+		},
+	}
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "overwrite if file exists")
+	return cmd
+}
+
+func newRenameCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:               "rename <old> <new>",
+		Aliases:           []string{"mv"},
+		Short:             "Rename a project and its running sessions",
+		Long:              "Rename moves a project file, sets its name field to the new name and renames the project's running tmux sessions, including those of profiles with a session_suffix.",
+		Args:              cobra.ExactArgs(2),
+		ValidArgsFunction: completeProjects,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			src, dst, err := projectNames(args[0], args[1])
+			if err != nil {
+				return err
+			}
+			// A project that fails to load can still be renamed, it just has no
+			// session to follow it
+			old, loadErr := loadProject(src)
+			var client tmux.Client
+			// suffixes of the running sessions, "" for the project's own
+			var running []string
+			if loadErr == nil {
+				// Check before moving the file, so nothing is half renamed
+				if c, err := newClient("tmux"); err == nil {
+					sessions := append([]profileSession{{Session: old.Name}}, profileSessions(old)...)
+					for _, s := range sessions {
+						if tmux.HasSession(c, s.Session) {
+							client = c
+							running = append(running, strings.TrimPrefix(s.Session, old.Name))
+						}
+					}
+				}
+			}
+			oldPath := cfg.ProjectFilePath(src)
+			path, err := cfg.RenameProject(src, dst)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "renamed %s to %s\n", oldPath, path)
+			if client == nil {
+				// Nothing running to rename
+				return nil
+			}
+
+			renamed, err := loadProject(dst)
+			if err != nil || renamed.Name == old.Name {
+				return err
+			}
+			for _, suffix := range running {
+				from, to := old.Name+suffix, renamed.Name+suffix
+				if err := tmux.RenameSession(client, from, to); err != nil {
+					return fmt.Errorf("renamed the project file but not session %s: %w", from, err)
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "renamed session %s to %s\n", from, to)
+			}
+			return nil
+		},
+	}
+}
+
+func newDeleteCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:               "delete <name>",
+		Aliases:           []string{"rm"},
+		Short:             "Delete a project file",
+		Long:              "Delete removes a project file, wherever in the search paths it is, after confirmation. A running session is left alone; stop it with lmux kill.",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeProjects,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := sanitizeName(args[0])
+			if name == "" {
+				return errors.New("invalid project name")
+			}
+			path := cfg.ProjectFilePath(name)
+			if _, err := os.Stat(path); err != nil {
+				return fmt.Errorf("project not found: %s", path)
+			}
+			confirmed, err := confirm(fmt.Sprintf("Delete %s?", describeProjectFile(path)))
+			if err != nil {
+				return err
+			}
+			if !confirmed {
+				fmt.Fprintln(cmd.OutOrStdout(), "aborted")
+				return nil
+			}
+			if err := cfg.DeleteProject(name); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "deleted %s\n", path)
+			return nil
+		},
+	}
+}
+
+// describeProjectFile returns path, noting when it is in a search path rather
+// than the config directory, for prompts about changing the file.
+func describeProjectFile(path string) string {
+	if dir, _ := cfg.EnsureConfigDir(); filepath.Dir(path) != dir {
+		return path + " (in a search path)"
+	}
+	return path
+}
+
+// projectNames sanitizes a source and destination project name pair.
+func projectNames(src, dst string) (string, string, error) {
+	s, d := sanitizeName(src), sanitizeName(dst)
+	if s == "" || d == "" {
+		return "", "", errors.New("invalid project name")
+	}
+	if s == d {
+		return "", "", fmt.Errorf("%s and %s are the same project", src, dst)
+	}
+	if _, err := os.Stat(cfg.ProjectFilePath(s)); err != nil {
+		return "", "", fmt.Errorf("project not found: %s", cfg.ProjectFilePath(s))
+	}
+	return s, d, nil
+}
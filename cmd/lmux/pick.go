@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/term"
+
+	cfg "github.com/sbcinnovation/lmux/internal/config"
+	"github.com/sbcinnovation/lmux/internal/picker"
+	"github.com/sbcinnovation/lmux/internal/tmux"
+)
+
+// pickTarget is what the user chose in the picker: a project file, or a
+// running session that has none.
+type pickTarget struct {
+	Project string
+	Session string
+}
+
+// isTerminal reports whether lmux can interact with the user; tests override it.
+var isTerminal = func() bool {
+	return term.IsTerminal(int(os.Stdin.Fd())) && term.IsTerminal(int(os.Stderr.Fd()))
+}
+
+// stdinIsTerminal reports whether prompts can be answered; tests override it.
+var stdinIsTerminal = func() bool {
+	return term.IsTerminal(int(os.Stdin.Fd()))
+}
+
+// runPicker shows the picker and returns the chosen item's value; tests swap
+// it out. It draws on stderr so stdout stays clean for structured output.
+var runPicker = func(prompt string, items []picker.Item) (string, error) {
+	return picker.Run(os.Stdin, os.Stderr, prompt, items)
+}
+
+// pickProject lets the user choose a project interactively. With
+// withSessions, running sessions without a project file are offered too.
+func pickProject(prompt string, withSessions bool) (pickTarget, error) {
+	names, err := cfg.ListProjects()
+	if err != nil {
+		return pickTarget{}, err
+	}
+	running := map[string]bool{}
+	if client, err := newClient("tmux"); err == nil {
+		sessions, _ := tmux.ListSessions(client)
+		for _, s := range sessions {
+			running[s] = true
+		}
+	}
+
+	var items []picker.Item
+	targets := map[string]pickTarget{}
+	covered := map[string]bool{}
+	for _, name := range names {
+		project, loadErr := loadProject(name)
+		session := name
+		if loadErr == nil {
+			session = project.Name
+		}
+		covered[session] = true
+		value := "project:" + name
+		targets[value] = pickTarget{Project: name}
+		items = append(items, picker.Item{
+			Label:   name,
+			Value:   value,
+			Running: running[session],
+			Preview: func() []string { return projectPreview(project, loadErr) },
+		})
+	}
+	if withSessions {
+		sessions := make([]string, 0, len(running))
+		for s := range running {
+			if !covered[s] {
+				sessions = append(sessions, s)
+			}
+		}
+		sort.Strings(sessions)
+		for _, s := range sessions {
+			value := "session:" + s
+			targets[value] = pickTarget{Session: s}
+			items = append(items, picker.Item{
+				Label:   s,
+				Value:   value,
+				Running: true,
+				Preview: func() []string { return []string{"running session without a project file"} },
+			})
+		}
+	}
+	if len(items) == 0 {
+		return pickTarget{}, errors.New("no projects found; create one with: lmux init <name>")
+	}
+
+	value, err := runPicker(prompt, items)
+	if err != nil {
+		return pickTarget{}, err
+	}
+	return targets[value], nil
+}
+
+// projectPreview describes a project's windows for the picker.
+func projectPreview(project cfg.Project, err error) []string {
+	if err != nil {
+		return []string{"error: " + err.Error()}
+	}
+	var lines []string
+	if project.Root != "" {
+		lines = append(lines, "root: "+project.Root)
+	}
+	for i, w := range project.Windows {
+		var detail string
+		switch {
+		case len(w.Panes) > 0:
+			titles := make([]string, len(w.Panes))
+			for j, p := range w.Panes {
+				titles[j] = p.Title
+				if titles[j] == "" {
+					titles[j] = strings.Join(p.Commands, "; ")
+				}
+			}
+			detail = fmt.Sprintf("%d panes: %s", len(w.Panes), strings.Join(titles, " | "))
+		default:
+			detail = strings.Join(w.Commands, "; ")
+		}
+		lines = append(lines, strings.TrimRight(fmt.Sprintf("  %d %s  %s", i+1, w.Name, detail), " "))
+	}
+	return lines
+}
+
+// projectArg returns the project named in args, or asks the user to pick one
+// when none was given in a terminal. missing is the error for the
+// non-interactive case.
+func projectArg(args []string, prompt string, missing error) (string, error) {
+	if len(args) > 0 {
+		return args[0], nil
+	}
+	if !isTerminal() {
+		return "", missing
+	}
+	target, err := pickProject(prompt, false)
+	if err != nil {
+		return "", err
+	}
+	return target.Project, nil
+}
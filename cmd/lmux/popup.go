@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sbcinnovation/lmux/internal/picker"
+	"github.com/sbcinnovation/lmux/internal/shell"
+	"github.com/sbcinnovation/lmux/internal/tmux"
+)
+
+// executable returns the path tmux should run to re-enter lmux; tests override it.
+var executable = os.Executable
+
+func newPopupCmd() *cobra.Command {
+	var width, height, clientName string
+	var inPopup bool
+	cmd := &cobra.Command{
+		Use:   "popup",
+		Short: "Switch projects from a picker in a tmux popup",
+		Long: `Popup opens the project picker in a tmux popup (display-popup, tmux 3.2+)
+and switches the current client to the chosen project, starting it first if
+needed. Run it from inside tmux, typically through a key binding; see
+lmux tmux-bindings.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if os.Getenv("TMUX") == "" {
+				return errors.New("lmux popup must run inside tmux")
+			}
+			client, err := newClient("tmux")
+			if err != nil {
+				return err
+			}
+			if clientName == "" {
+				if clientName, err = tmux.CurrentClient(client); err != nil {
+					return err
+				}
+			}
+			if inPopup {
+				return switchFromPopup(client, clientName)
+			}
+
+			exe, err := executable()
+			if err != nil {
+				return err
+			}
+			shellCmd := shell.Join([]string{exe, "popup", "--in-popup", "--client", clientName})
+			return client.Run("display-popup", "-E", "-c", clientName, "-w", width, "-h", height, "-T", " lmux ", shellCmd)
+		},
+	}
+	cmd.Flags().StringVar(&width, "width", "60%", "popup width, in cells or a percentage")
+	cmd.Flags().StringVar(&height, "height", "60%", "popup height, in cells or a percentage")
+	cmd.Flags().StringVar(&clientName, "client", "", "tmux client to switch (default: the current client)")
+	cmd.Flags().BoolVar(&inPopup, "in-popup", false, "run the picker in this terminal instead of opening a popup")
+	_ = cmd.Flags().MarkHidden("in-popup")
+	return cmd
+}
+
+// switchFromPopup runs the picker and switches clientName to the choice.
+func switchFromPopup(client tmux.Client, clientName string) error {
+	target, err := pickProject("switch> ", true)
+	if errors.Is(err, picker.ErrCanceled) {
+		// Closing the popup is the answer
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	session := target.Session
+	if target.Project != "" {
+		project, err := loadProject(target.Project)
+		if err != nil {
+			return err
+		}
+		if err := startProject(project, false); err != nil {
+			return err
+		}
+		session = project.Name
+	}
+	return tmux.SwitchClient(client, clientName, session)
+}
+
+func newTmuxBindingsCmd() *cobra.Command {
+	var popupKey, uiKey string
+	cmd := &cobra.Command{
+		Use:   "tmux-bindings",
+		Short: "Print tmux key bindings for the lmux popup and dashboard",
+		Long:  "Tmux-bindings prints key bindings to add to ~/.tmux.conf.",
+		Example: `  lmux tmux-bindings >> ~/.tmux.conf && tmux source-file ~/.tmux.conf
+  lmux tmux-bindings --popup-key C-j --ui-key C-u`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+			fmt.Fprintln(out, "# lmux key bindings (tmux 3.2+)")
+			fmt.Fprintf(out, "# prefix + %s: switch projects from a popup picker\n", popupKey)
+			fmt.Fprintf(out, "bind-key %s run-shell \"lmux popup\"\n", popupKey)
+			fmt.Fprintf(out, "# prefix + %s: open the lmux dashboard in a popup\n", uiKey)
+			fmt.Fprintf(out, "bind-key %s display-popup -E -w 90%% -h 90%% -T \" lmux \" \"lmux ui\"\n", uiKey)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&popupKey, "popup-key", "P", "key that opens the popup picker")
+	cmd.Flags().StringVar(&uiKey, "ui-key", "U", "key that opens the dashboard")
+	return cmd
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"gopkg.in/yaml.v3"
+)
+
+// outputFormat is set by the global --output flag.
+var outputFormat = "table"
+
+// validateOutputFormat rejects unknown --output values before a command runs.
+func validateOutputFormat() error {
+	return checkFormat(outputFormat)
+}
+
+func checkFormat(format string) error {
+	switch format {
+	case "table", "json", "yaml":
+		return nil
+	default:
+		return fmt.Errorf("invalid --output %q (want table, json or yaml)", format)
+	}
+}
+
+// structuredOutput reports whether results are rendered for scripts rather than people.
+func structuredOutput() bool {
+	return outputFormat == "json" || outputFormat == "yaml"
+}
+
+// render writes a command's result in the selected format. table prints the
+// human-readable form and is only called for the table format.
+func render(out io.Writer, result any, table func(io.Writer) error) error {
+	return renderAs(out, outputFormat, result, table)
+}
+
+// renderAs is render with an explicit format, for commands with their own format flags.
+func renderAs(out io.Writer, format string, result any, table func(io.Writer) error) error {
+	if err := checkFormat(format); err != nil {
+		return err
+	}
+	switch format {
+	case "json":
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		return enc.Encode(result)
+	case "yaml":
+		enc := yaml.NewEncoder(out)
+		enc.SetIndent(2)
+		if err := enc.Encode(result); err != nil {
+			return err
+		}
+		return enc.Close()
+	default:
+		return table(out)
+	}
+}
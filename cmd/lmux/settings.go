@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	cfg "github.com/sbcinnovation/lmux/internal/config"
+)
+
+// settingEntry is one setting reported by `lmux config list` and `get`.
+type settingEntry struct {
+	Key string `json:"key" yaml:"key"`
+	// Value is a string, bool or list of strings, or nil if unset.
+	Value any    `json:"value" yaml:"value"`
+	Usage string `json:"usage" yaml:"usage"`
+}
+
+func newConfigCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Get and set lmux settings",
+		Long: `Config manages ~/.config/lmux/settings.toml. Besides the editor, settings hold
+defaults for projects that leave tmux_command, tmux_options, attach, root,
+pre_window or layout unset; strict, which rejects unknown keys in project
+files; and search_paths, directories searched for projects after
+~/.config/lmux.`,
+		Example: `  lmux config list
+  lmux config set attach false
+  lmux config set pre_window "source .env"
+  lmux config set search_paths ~/work/lmux ~/dotfiles/lmux
+  lmux config unset root`,
+	}
+	cmd.AddCommand(newConfigListCmd(), newConfigGetCmd(), newConfigSetCmd(), newConfigUnsetCmd())
+	return cmd
+}
+
+func newConfigListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "List every setting and its value",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			settings, err := cfg.LoadSettings()
+			if err != nil {
+				return err
+			}
+			var entries []settingEntry
+			for _, key := range cfg.SettingKeys() {
+				value, err := settings.Get(key.Name)
+				if err != nil {
+					return err
+				}
+				entries = append(entries, settingEntry{Key: key.Name, Value: value, Usage: key.Usage})
+			}
+			return render(cmd.OutOrStdout(), entries, func(out io.Writer) error {
+				w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+				fmt.Fprintln(w, "KEY\tVALUE\tDESCRIPTION")
+				for _, e := range entries {
+					value := formatSetting(e.Value, ", ")
+					if value == "" {
+						value = "-"
+					}
+					fmt.Fprintf(w, "%s\t%s\t%s\n", e.Key, value, e.Usage)
+				}
+				return w.Flush()
+			})
+		},
+	}
+}
+
+func newConfigGetCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:               "get <key>",
+		Short:             "Print a setting; list values print one per line",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeSettingKeys,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			settings, err := cfg.LoadSettings()
+			if err != nil {
+				return err
+			}
+			value, err := settings.Get(args[0])
+			if err != nil {
+				return err
+			}
+			entry := settingEntry{Key: args[0], Value: value}
+			for _, key := range cfg.SettingKeys() {
+				if key.Name == args[0] {
+					entry.Usage = key.Usage
+				}
+			}
+			return render(cmd.OutOrStdout(), entry, func(out io.Writer) error {
+				if value != nil {
+					fmt.Fprintln(out, formatSetting(value, "\n"))
+				}
+				return nil
+			})
+		},
+	}
+}
+
+func newConfigSetCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:               "set <key> <value>...",
+		Short:             "Set a setting; list settings take several values",
+		Args:              cobra.MinimumNArgs(2),
+		ValidArgsFunction: completeSettingKeys,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return updateSettings(func(s *cfg.Settings) error { return s.Set(args[0], args[1:]...) })
+		},
+	}
+}
+
+func newConfigUnsetCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:               "unset <key>",
+		Short:             "Remove a setting so its default applies",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeSettingKeys,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return updateSettings(func(s *cfg.Settings) error { return s.Unset(args[0]) })
+		},
+	}
+}
+
+func updateSettings(change func(*cfg.Settings) error) error {
+	settings, err := cfg.LoadSettings()
+	if err != nil {
+		return err
+	}
+	if err := change(&settings); err != nil {
+		return err
+	}
+	return cfg.SaveSettings(settings)
+}
+
+// formatSetting renders a setting value for people, joining lists with sep.
+func formatSetting(value any, sep string) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case []string:
+		return strings.Join(v, sep)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// completeSettingKeys completes the key argument of the config subcommands.
+func completeSettingKeys(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var keys []string
+	for _, key := range cfg.SettingKeys() {
+		if strings.HasPrefix(key.Name, toComplete) {
+			keys = append(keys, key.Name+"\t"+key.Usage)
+		}
+	}
+	return keys, cobra.ShellCompDirectiveNoFileComp
+}
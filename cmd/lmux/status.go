@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	cfg "github.com/sbcinnovation/lmux/internal/config"
+	"github.com/sbcinnovation/lmux/internal/tmux"
+)
+
+// projectStatus is one row of `lmux status`.
+type projectStatus struct {
+	Project string `json:"project,omitempty" yaml:"project,omitempty"`
+	// Profile is set for the session of a profile with a session_suffix.
+	Profile  string       `json:"profile,omitempty" yaml:"profile,omitempty"`
+	Session  string       `json:"session" yaml:"session"`
+	Running  bool         `json:"running" yaml:"running"`
+	Attached int          `json:"attached" yaml:"attached"`
+	Windows  int          `json:"windows" yaml:"windows"`
+	Created  *time.Time   `json:"created,omitempty" yaml:"created,omitempty"`
+	Uptime   string       `json:"uptime,omitempty" yaml:"uptime,omitempty"`
+	Panes    []paneStatus `json:"panes,omitempty" yaml:"panes,omitempty"`
+	Error    string       `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+type paneStatus struct {
+	Window      string `json:"window" yaml:"window"`
+	WindowIndex int    `json:"window_index" yaml:"window_index"`
+	Pane        int    `json:"pane" yaml:"pane"`
+	Command     string `json:"command" yaml:"command"`
+}
+
+func newStatusCmd() *cobra.Command {
+	var asJSON bool
+	cmd := &cobra.Command{
+		Use:     "status",
+		Aliases: []string{"ps"},
+		Short:   "Show which projects are running and what their panes are doing",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := newClient("tmux")
+			if err != nil {
+				return err
+			}
+			statuses, err := collectStatus(client, time.Now())
+			if err != nil {
+				return err
+			}
+			format := outputFormat
+			if asJSON {
+				format = "json"
+			}
+			if statuses == nil {
+				statuses = []projectStatus{}
+			}
+			return renderAs(cmd.OutOrStdout(), format, statuses, func(out io.Writer) error {
+				return printStatus(out, statuses)
+			})
+		},
+	}
+	cmd.Flags().BoolVar(&asJSON, "json", false, "shorthand for --output json")
+	return cmd
+}
+
+// collectStatus matches project files to running sessions. Sessions that no
+// project starts are listed after the projects.
+func collectStatus(client tmux.Client, now time.Time) ([]projectStatus, error) {
+	names, err := cfg.ListProjects()
+	if err != nil {
+		return nil, err
+	}
+	sessions, err := tmux.Sessions(client)
+	if err != nil {
+		return nil, err
+	}
+	running := make(map[string]tmux.Session, len(sessions))
+	for _, s := range sessions {
+		running[s.Name] = s
+	}
+
+	var statuses []projectStatus
+	claimed := map[string]bool{}
+	for _, name := range names {
+		st := projectStatus{Project: name, Session: name}
+		project, err := loadProject(name)
+		if err != nil {
+			st.Error = err.Error()
+		} else {
+			st.Session = project.Name
+		}
+		if s, ok := running[st.Session]; ok {
+			claimed[s.Name] = true
+			fillSessionStatus(client, &st, s, now)
+		}
+		statuses = append(statuses, st)
+		if err != nil {
+			continue
+		}
+		// Profile sessions are listed only while they run
+		for _, ps := range profileSessions(project) {
+			if s, ok := running[ps.Session]; ok && !claimed[s.Name] {
+				claimed[s.Name] = true
+				st := projectStatus{Project: name, Profile: ps.Profile, Session: ps.Session}
+				fillSessionStatus(client, &st, s, now)
+				statuses = append(statuses, st)
+			}
+		}
+	}
+	for _, s := range sessions {
+		if !claimed[s.Name] {
+			st := projectStatus{Session: s.Name}
+			fillSessionStatus(client, &st, s, now)
+			statuses = append(statuses, st)
+		}
+	}
+	return statuses, nil
+}
+
+// label names the row's project, with its profile if any, or "-" for a
+// session no project starts.
+func (st projectStatus) label() string {
+	switch {
+	case st.Project == "":
+		return "-"
+	case st.Profile != "":
+		return st.Project + " (" + st.Profile + ")"
+	default:
+		return st.Project
+	}
+}
+
+func fillSessionStatus(client tmux.Client, st *projectStatus, s tmux.Session, now time.Time) {
+	st.Running = true
+	st.Attached = s.Attached
+	st.Windows = s.Windows
+	if !s.Created.IsZero() {
+		created := s.Created
+		st.Created = &created
+		st.Uptime = formatUptime(now.Sub(created))
+	}
+	panes, err := tmux.Panes(client, s.Name)
+	if err != nil {
+		st.Error = err.Error()
+		return
+	}
+	for _, p := range panes {
+		st.Panes = append(st.Panes, paneStatus{Window: p.WindowName, WindowIndex: p.WindowIndex, Pane: p.Index, Command: p.Command})
+	}
+}
+
+func printStatus(out io.Writer, statuses []projectStatus) error {
+	if len(statuses) == 0 {
+		fmt.Fprintln(out, "No projects or sessions found.")
+		return nil
+	}
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PROJECT\tSESSION\tSTATE\tCLIENTS\tWINDOWS\tUPTIME")
+	for _, st := range statuses {
+		project := st.label()
+		if !st.Running {
+			state := "stopped"
+			if st.Error != "" {
+				state = "invalid"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t-\t-\t-\n", project, st.Session, state)
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\trunning\t%d\t%d\t%s\n", project, st.Session, st.Attached, st.Windows, st.Uptime)
+		for _, p := range st.Panes {
+			fmt.Fprintf(w, "  %d:%s.%d\t%s\n", p.WindowIndex, p.Window, p.Pane, p.Command)
+		}
+	}
+	return w.Flush()
+}
+
+// formatUptime renders a duration compactly, e.g. "45s" or "2h5m".
+func formatUptime(d time.Duration) string {
+	if d < time.Minute {
+		return d.Round(time.Second).String()
+	}
+	s := d.Truncate(time.Minute).String()
+	return s[:len(s)-2] // drop the trailing "0s"
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	cfg "github.com/sbcinnovation/lmux/internal/config"
+	"github.com/sbcinnovation/lmux/internal/tmux"
+	"github.com/sbcinnovation/lmux/internal/tui"
+)
+
+// outputLines is how much recent pane output the dashboard shows.
+const outputLines = 10
+
+func newUICmd() *cobra.Command {
+	var refresh time.Duration
+	cmd := &cobra.Command{
+		Use:   "ui",
+		Short: "Manage projects and sessions from a full-screen dashboard",
+		Long: `UI lists every project and running session with its panes and recent output.
+
+Keys: up/down (or j/k) select, enter/a attach, s start, x stop, r restart,
+e edit, d delete, ctrl-l refresh, q quit. The list refreshes on its own.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if refresh <= 0 {
+				return errors.New("--refresh must be positive")
+			}
+			client, err := newClient("tmux")
+			if err != nil {
+				return err
+			}
+			t, err := tui.Open(os.Stdin, os.Stdout)
+			if err != nil {
+				return err
+			}
+			defer t.Close()
+			return runDashboard(t, newDashboard(client), refresh)
+		},
+	}
+	cmd.Flags().DurationVar(&refresh, "refresh", 2*time.Second, "how often to refresh sessions")
+	return cmd
+}
+
+func runDashboard(t *tui.Terminal, d *dashboard, refresh time.Duration) error {
+	d.reload()
+	for {
+		t.Draw(d.view(t.Size()))
+		keys, err := t.ReadKeys(refresh)
+		if err != nil {
+			return err
+		}
+		if len(keys) == 0 {
+			d.reload()
+			continue
+		}
+		for _, k := range keys {
+			if d.handleKey(k) {
+				return nil
+			}
+			if d.external != nil {
+				// Attaching and editing need the terminal to themselves
+				run := d.external
+				d.external = nil
+				if err := t.Suspend(); err != nil {
+					return err
+				}
+				if err := run(); err != nil {
+					d.message = err.Error()
+				}
+				if err := t.Resume(); err != nil {
+					return err
+				}
+				d.reload()
+			}
+		}
+	}
+}
+
+// dashboard is the state of `lmux ui`, kept apart from the terminal.
+type dashboard struct {
+	client  tmux.Client
+	now     func() time.Time
+	rows    []projectStatus
+	cursor  int
+	output  []string
+	message string
+
+	// confirm, when set, runs once the user answers y to message.
+	confirm func() error
+	// external, when set, is run by the caller with the terminal released.
+	external func() error
+}
+
+func newDashboard(client tmux.Client) *dashboard {
+	return &dashboard{client: client, now: time.Now}
+}
+
+// reload refreshes projects and sessions, keeping the same row selected.
+func (d *dashboard) reload() {
+	var current string
+	if row, ok := d.selected(); ok {
+		current = row.Project + "\x00" + row.Session
+	}
+	rows, err := collectStatus(d.client, d.now())
+	if err != nil {
+		d.message = err.Error()
+		return
+	}
+	d.rows = rows
+	d.cursor = 0
+	for i, row := range rows {
+		if row.Project+"\x00"+row.Session == current {
+			d.cursor = i
+		}
+	}
+	d.captureOutput()
+}
+
+func (d *dashboard) selected() (projectStatus, bool) {
+	if d.cursor >= len(d.rows) {
+		return projectStatus{}, false
+	}
+	return d.rows[d.cursor], true
+}
+
+// captureOutput fetches recent output from the selected session's active pane.
+func (d *dashboard) captureOutput() {
+	d.output = nil
+	row, ok := d.selected()
+	if !ok || !row.Running {
+		return
+	}
+	lines, err := tmux.CapturePane(d.client, "="+row.Session+":", outputLines)
+	if err != nil {
+		d.output = []string{err.Error()}
+		return
+	}
+	d.output = lines
+}
+
+// handleKey applies a keypress and reports whether the dashboard should quit.
+func (d *dashboard) handleKey(k tui.Key) bool {
+	if d.confirm != nil {
+		run := d.confirm
+		d.confirm = nil
+		d.message = ""
+		if k.Rune == 'y' || k.Rune == 'Y' {
+			d.finish(run())
+		}
+		return false
+	}
+
+	switch {
+	case k.Name == tui.KeyEscape || k.Rune == 'q':
+		return true
+	case k.Name == tui.KeyUp || k.Rune == 'k':
+		if d.cursor > 0 {
+			d.cursor--
+			d.captureOutput()
+		}
+		return false
+	case k.Name == tui.KeyDown || k.Rune == 'j':
+		if d.cursor < len(d.rows)-1 {
+			d.cursor++
+			d.captureOutput()
+		}
+		return false
+	case k.Name == tui.KeyRefresh:
+		d.message = ""
+		d.reload()
+		return false
+	}
+
+	row, ok := d.selected()
+	if !ok {
+		return false
+	}
+	d.message = ""
+	switch {
+	case k.Name == tui.KeyEnter || k.Rune == 'a':
+		d.external = func() error { return d.attach(row) }
+	case k.Rune == 's':
+		if row.Running {
+			d.message = row.Session + " is already running"
+			return false
+		}
+		d.finish(d.start(row))
+	case k.Rune == 'x':
+		if !row.Running {
+			d.message = row.Session + " is not running"
+			return false
+		}
+		d.ask(fmt.Sprintf("Stop session %q? [y/N]", row.Session), func() error {
+			return tmux.KillSession(d.client, row.Session)
+		})
+	case k.Rune == 'r':
+		if row.Project == "" {
+			d.message = row.Session + " has no project file to restart from"
+			return false
+		}
+		d.ask(fmt.Sprintf("Restart %q? [y/N]", row.Project), func() error {
+			if row.Running {
+				if err := tmux.KillSession(d.client, row.Session); err != nil {
+					return err
+				}
+			}
+			return d.start(row)
+		})
+	case k.Rune == 'e':
+		if row.Project == "" {
+			d.message = row.Session + " has no project file"
+			return false
+		}
+		d.external = func() error { return editProject(cfg.ProjectFilePath(row.Project), false) }
+	case k.Rune == 'd':
+		if row.Project == "" {
+			d.message = row.Session + " has no project file"
+			return false
+		}
+		d.ask(fmt.Sprintf("Delete project file %s? [y/N]", describeProjectFile(cfg.ProjectFilePath(row.Project))), func() error {
+			return cfg.DeleteProject(row.Project)
+		})
+	}
+	return false
+}
+
+func (d *dashboard) ask(prompt string, run func() error) {
+	d.message = prompt
+	d.confirm = run
+}
+
+func (d *dashboard) finish(err error) {
+	if err != nil {
+		d.message = err.Error()
+	}
+	d.reload()
+}
+
+func (d *dashboard) start(row projectStatus) error {
+	project, err := loadProjectProfile(row.Project, row.Profile)
+	if err != nil {
+		return err
+	}
+	return startProject(project, false)
+}
+
+// attach switches to the row's session, starting it first if needed.
+func (d *dashboard) attach(row projectStatus) error {
+	if !row.Running {
+		if row.Project == "" {
+			return fmt.Errorf("%s is not running", row.Session)
+		}
+		if err := d.start(row); err != nil {
+			return err
+		}
+	}
+	return tmux.AttachSession(d.client, row.Session)
+}
+
+// view renders the dashboard and returns the cursor position with it.
+func (d *dashboard) view(width, height int) ([]string, int, int) {
+	running := 0
+	for _, row := range d.rows {
+		if row.Running {
+			running++
+		}
+	}
+	lines := []string{fmt.Sprintf("\x1b[1mlmux\x1b[0m  %d projects and sessions, %d running", len(d.rows), running), ""}
+
+	var table bytes.Buffer
+	w := tabwriter.NewWriter(&table, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "    PROJECT\tSESSION\tSTATE\tCLIENTS\tWINDOWS\tUPTIME")
+	for _, row := range d.rows {
+		marker := " "
+		if row.Running {
+			marker = "●"
+		}
+		project := row.label()
+		state, clients, windows, uptime := "stopped", "-", "-", "-"
+		if row.Error != "" && !row.Running {
+			state = "invalid"
+		}
+		if row.Running {
+			state, clients, windows, uptime = "running", fmt.Sprint(row.Attached), fmt.Sprint(row.Windows), row.Uptime
+		}
+		fmt.Fprintf(w, "  %s %s\t%s\t%s\t%s\t%s\t%s\n", marker, project, row.Session, state, clients, windows, uptime)
+	}
+	w.Flush()
+	tableLines := strings.Split(strings.TrimRight(table.String(), "\n"), "\n")
+	lines = append(lines, "\x1b[2m"+tui.Truncate(tableLines[0], width)+"\x1b[0m")
+	for i, l := range tableLines[1:] {
+		l = tui.Truncate(l, width)
+		if i == d.cursor {
+			l = "\x1b[7m>" + l[1:] + "\x1b[0m"
+		}
+		lines = append(lines, l)
+	}
+	if len(d.rows) == 0 {
+		lines = append(lines, "  No projects or sessions found. Create one with: lmux init <name>")
+	}
+
+	if row, ok := d.selected(); ok {
+		lines = append(lines, "")
+		if row.Error != "" {
+			lines = append(lines, tui.Truncate("error: "+row.Error, width))
+		}
+		if len(row.Panes) > 0 {
+			lines = append(lines, "\x1b[1mPanes\x1b[0m")
+			for _, p := range row.Panes {
+				lines = append(lines, tui.Truncate(fmt.Sprintf("  %d:%s.%d  %s", p.WindowIndex, p.Window, p.Pane, p.Command), width))
+			}
+		}
+		if len(d.output) > 0 {
+			lines = append(lines, "\x1b[1mRecent output\x1b[0m")
+			for _, l := range d.output {
+				lines = append(lines, tui.Truncate("  "+l, width))
+			}
+		}
+	}
+
+	// Keep the key help and any message pinned to the bottom
+	footer := []string{"\x1b[2menter attach  s start  x stop  r restart  e edit  d delete  ctrl-l refresh  q quit\x1b[0m"}
+	if d.message != "" {
+		footer = append(footer, tui.Truncate(d.message, width))
+	}
+	if room := height - len(footer); len(lines) > room {
+		lines = lines[:max(room, 0)]
+	}
+	for len(lines) < height-len(footer) {
+		lines = append(lines, "")
+	}
+	lines = append(lines, footer...)
+	last := len(lines) - 1
+	return lines, last, tui.VisibleLen(lines[last])
+}
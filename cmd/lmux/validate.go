@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	cfg "github.com/sbcinnovation/lmux/internal/config"
+)
+
+// validateResult is the check of one project reported by `lmux validate`.
+type validateResult struct {
+	Project string `json:"project" yaml:"project"`
+	Path    string `json:"path" yaml:"path"`
+	Valid   bool   `json:"valid" yaml:"valid"`
+	Error   string `json:"error,omitempty" yaml:"error,omitempty"`
+	// Line is where in the file the error is, when known.
+	Line int `json:"line,omitempty" yaml:"line,omitempty"`
+}
+
+func newValidateCmd() *cobra.Command {
+	var host, osName string
+	cmd := &cobra.Command{
+		Use:   "validate [name...]",
+		Short: "Check that project files load",
+		Long: `Validate loads the named projects, or every project, and reports any errors.
+
+Every profile of a project is checked too. Projects load with the overrides
+for this machine; --host and --os check the variant another machine would get
+instead.`,
+		Example: `  lmux validate
+  lmux validate api --host devbox
+  lmux validate api --os wsl`,
+		ValidArgsFunction: completeProjects,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			platform := cfg.CurrentPlatform()
+			if host != "" {
+				platform.Host = host
+			}
+			if osName != "" {
+				platform.OS = osName
+			}
+			names := args
+			if len(names) == 0 {
+				var err error
+				if names, err = cfg.ListProjects(); err != nil {
+					return err
+				}
+			}
+
+			results := make([]validateResult, len(names))
+			invalid := 0
+			for i, arg := range names {
+				name := sanitizeName(arg)
+				path := cfg.ProjectFilePath(name)
+				results[i] = validateResult{Project: name, Path: path, Valid: true}
+				if err := validateProject(path, platform); err != nil {
+					results[i] = validateResult{Project: name, Path: path, Error: err.Error(), Line: cfg.ErrorLine(err)}
+					invalid++
+				}
+			}
+			err := render(cmd.OutOrStdout(), results, func(out io.Writer) error {
+				w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+				for _, r := range results {
+					switch {
+					case r.Valid:
+						fmt.Fprintf(w, "ok\t%s\n", r.Project)
+					case r.Line > 0:
+						fmt.Fprintf(w, "invalid\t%s\t%s:%d: %s\n", r.Project, r.Path, r.Line, r.Error)
+					default:
+						fmt.Fprintf(w, "invalid\t%s\t%s: %s\n", r.Project, r.Path, r.Error)
+					}
+				}
+				return w.Flush()
+			})
+			if err != nil {
+				return err
+			}
+			if invalid > 0 {
+				return fmt.Errorf("%d of %d projects are invalid", invalid, len(results))
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&host, "host", "", "apply the overrides for this host name instead of the local one")
+	cmd.Flags().StringVar(&osName, "os", "", "apply the overrides for this OS (linux, darwin, windows, wsl) instead of the local one")
+	_ = cmd.RegisterFlagCompletionFunc("os", cobra.FixedCompletions([]string{"linux", "darwin", "windows", "wsl", "freebsd"}, cobra.ShellCompDirectiveNoFileComp))
+	return cmd
+}
+
+// validateProject loads the project at path and each of its profiles.
+func validateProject(path string, platform cfg.Platform) error {
+	project, err := cfg.LoadProjectFileFor(path, platform, "")
+	if err != nil {
+		return err
+	}
+	for _, profile := range project.ProfileNames() {
+		if _, err := cfg.LoadProjectFileFor(path, platform, profile); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	cfg "github.com/sbcinnovation/lmux/internal/config"
+	"github.com/sbcinnovation/lmux/internal/tmux"
+)
+
+// startResult is the outcome of starting one of several projects.
+type startResult struct {
+	Project string `json:"project" yaml:"project"`
+	Session string `json:"session,omitempty" yaml:"session,omitempty"`
+	// Status is "started", "running" if the session already existed, or
+	// "failed".
+	Status string `json:"status" yaml:"status"`
+	Error  string `json:"error,omitempty" yaml:"error,omitempty"`
+
+	project cfg.Project
+	attach  bool
+}
+
+// startWorkspace returns the named workspace, or one made of the projects
+// named on the command line.
+func startWorkspace(name string, projects []string) (cfg.Workspace, error) {
+	if name != "" {
+		return cfg.LoadWorkspace(name)
+	}
+	ws := cfg.Workspace{Primary: projects[0], Jobs: cfg.DefaultWorkspaceJobs}
+	for _, p := range projects {
+		if workspaceHas(ws, p) {
+			return ws, fmt.Errorf("project %s is named twice", p)
+		}
+		ws.Projects = append(ws.Projects, cfg.WorkspaceProject{Name: p})
+	}
+	return ws, nil
+}
+
+func workspaceHas(ws cfg.Workspace, project string) bool {
+	for _, p := range ws.Projects {
+		if p.Name == project {
+			return true
+		}
+	}
+	return false
+}
+
+// startMany starts the workspace's projects, jobs at a time, reports how each
+// went and attaches to the primary project once all have started. attach
+// overrides the primary project's attach value when non-nil.
+func startMany(cmd *cobra.Command, ws cfg.Workspace, attach *bool) error {
+	results := startProjects(ws.Projects, ws.Jobs)
+	err := render(cmd.OutOrStdout(), results, func(out io.Writer) error {
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		for _, r := range results {
+			switch {
+			case r.Error != "":
+				fmt.Fprintf(w, "%s\t%s\t%s\n", r.Status, r.Project, r.Error)
+			case r.Session != r.Project:
+				fmt.Fprintf(w, "%s\t%s\tas %s\n", r.Status, r.Project, r.Session)
+			default:
+				fmt.Fprintf(w, "%s\t%s\n", r.Status, r.Project)
+			}
+		}
+		return w.Flush()
+	})
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	var primary startResult
+	for _, r := range results {
+		if r.Status == "failed" {
+			failed++
+		}
+		if r.Project == ws.Primary && primary.Project == "" {
+			primary = r
+		}
+	}
+	// Attaching would hide the report, so stay here for the failures
+	if failed > 0 {
+		return fmt.Errorf("%d of %d projects failed to start", failed, len(results))
+	}
+	if attach != nil {
+		primary.attach = *attach
+	}
+	if !primary.attach {
+		return nil
+	}
+	client, err := projectClient(primary.project)
+	if err != nil {
+		return err
+	}
+	return tmux.AttachSession(client, primary.Session)
+}
+
+// startProjects starts the projects with a pool of jobs workers and returns
+// their results in the projects' order.
+func startProjects(projects []cfg.WorkspaceProject, jobs int) []startResult {
+	results := make([]startResult, len(projects))
+	work := make(chan int)
+	var wg sync.WaitGroup
+	for range min(max(jobs, 1), len(projects)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				results[i] = startWorkspaceProject(projects[i])
+			}
+		}()
+	}
+	for i := range projects {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+	return results
+}
+
+// startWorkspaceProject loads and starts one project without attaching.
+func startWorkspaceProject(wp cfg.WorkspaceProject) startResult {
+	result := startResult{Project: wp.Name, Status: "failed"}
+	project, err := loadProjectProfile(wp.Name, wp.Profile)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Session = project.Name
+	result.attach = *project.Attach
+	result.project = project
+	if wp.Root != "" {
+		project.Root = cfg.ExpandPath(wp.Root)
+	}
+	if err := project.FilterWindows(wp.Only, wp.Skip); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	client, err := buildClient(project)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	if closer, ok := client.(io.Closer); ok {
+		defer closer.Close()
+	}
+	status := "started"
+	if tmux.HasSession(client, project.Name) {
+		status = "running"
+	}
+	if err := tmux.StartProject(client, project, false); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Status = status
+	return result
+}
+
+// completeStartProjects completes start's project names, leaving out those
+// already given.
+func completeStartProjects(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if workspace, _ := cmd.Flags().GetString("workspace"); workspace != "" {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var completions []string
+	for _, c := range projectCompletions(toComplete, false) {
+		name, _, _ := strings.Cut(c, "\t")
+		if !slices.Contains(args, name) {
+			completions = append(completions, c)
+		}
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completePrimary completes --primary with the projects being started.
+func completePrimary(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	names := args
+	if workspace, _ := cmd.Flags().GetString("workspace"); workspace != "" {
+		ws, err := cfg.LoadWorkspace(workspace)
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		names = nil
+		for _, p := range ws.Projects {
+			names = append(names, p.Name)
+		}
+	}
+	var completions []string
+	for _, name := range names {
+		if strings.HasPrefix(name, toComplete) && !slices.Contains(completions, name) {
+			completions = append(completions, name)
+		}
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeWorkspaces completes the --workspace flag of start.
+func completeWorkspaces(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	names, err := cfg.ListWorkspaces()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var completions []string
+	for _, name := range names {
+		if strings.HasPrefix(name, toComplete) {
+			completions = append(completions, name)
+		}
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp
+}
@@ -1,13 +1,22 @@
 package config
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"io/fs"
+	"net"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/pelletier/go-toml/v2"
+
+	"github.com/sbcinnovation/lmux/internal/shell"
 )
 
 // Project represents the lmux project configuration.
@@ -20,10 +29,34 @@ type Project struct {
 	TmuxOptions   string `toml:"tmux_options,omitempty"`
 	StartupWindow string `toml:"startup_window,omitempty"`
 	StartupPane   int    `toml:"startup_pane,omitempty"`
-	WindowsRaw    []any  `toml:"windows"`
+	// PreWindowRaw holds commands run in every pane before its own, as a
+	// string or array.
+	PreWindowRaw any `toml:"pre_window,omitempty"`
+	// Layout is the layout of windows with panes that do not set one.
+	Layout     string `toml:"layout,omitempty"`
+	WindowsRaw []any  `toml:"windows"`
+	// Procfile and Compose name files, relative to Root, whose processes and
+	// services become windows after the declared ones.
+	Procfile string `toml:"procfile,omitempty"`
+	Compose  string `toml:"compose,omitempty"`
+	// Overrides are merged onto the project on matching machines, keyed by
+	// "host:NAME" or "os:NAME".
+	Overrides map[string]Project `toml:"overrides,omitempty"`
+	// Profiles are named variants selected when the project is loaded.
+	Profiles map[string]Profile `toml:"profiles,omitempty"`
+	// Profile is the name of the profile the project was loaded with.
+	Profile string `toml:"-"`
 
 	// Normalized
-	Windows []Window `toml:"-"`
+	PreWindow []string `toml:"-"`
+	Windows   []Window `toml:"-"`
+	// TmuxFlags are tmux_options split into words, given to tmux before
+	// every command that builds or attaches to the session.
+	TmuxFlags []string `toml:"-"`
+
+	// platform is the one the project was loaded for, which when
+	// conditions match.
+	platform Platform
 }
 
 // Window is the normalized representation after parsing WindowsRaw.
@@ -33,12 +66,66 @@ type Window struct {
 	Root     string
 	Commands []string
 	Panes    []Pane
+	WaitFor  *WaitFor
+	// DependsOn names windows that must be ready before this window's commands run.
+	DependsOn []string
+	// When drops the window unless its conditions hold.
+	When *When
+}
+
+// applyDefaults fills the window from the project-wide layout and prefixes
+// the project's pre_window commands to every pane.
+func (w *Window) applyDefaults(p *Project) {
+	if len(w.Panes) == 0 {
+		w.Commands = append(append([]string(nil), p.PreWindow...), w.Commands...)
+		return
+	}
+	if w.Layout == "" {
+		w.Layout = p.Layout
+	}
+	for i := range w.Panes {
+		w.Panes[i].Commands = append(append([]string(nil), p.PreWindow...), w.Panes[i].Commands...)
+	}
 }
 
 // Pane represents commands inside a window split. Title is optional and not used yet.
 type Pane struct {
 	Title    string
 	Commands []string
+	WaitFor  *WaitFor
+	When     *When
+}
+
+// DefaultWaitTimeout bounds a wait_for check that sets no timeout.
+const DefaultWaitTimeout = 30 * time.Second
+
+// WaitFor describes when a window or pane is ready. Commands for later
+// windows and panes are only sent once every set condition holds.
+type WaitFor struct {
+	Port    int            // TCP port accepting connections on Host
+	Host    string         // host for Port, default localhost
+	File    string         // path that must exist, relative to the window root
+	Command string         // shell command that must exit successfully
+	Output  *regexp.Regexp // pattern a line of the pane's visible output must match
+	Timeout time.Duration
+}
+
+// String summarizes the conditions, e.g. "port 8080 and file tmp/ready".
+func (w WaitFor) String() string {
+	var parts []string
+	if w.Port != 0 {
+		parts = append(parts, fmt.Sprintf("port %s", net.JoinHostPort(w.Host, strconv.Itoa(w.Port))))
+	}
+	if w.File != "" {
+		parts = append(parts, fmt.Sprintf("file %s", w.File))
+	}
+	if w.Command != "" {
+		parts = append(parts, fmt.Sprintf("command %q", w.Command))
+	}
+	if w.Output != nil {
+		parts = append(parts, fmt.Sprintf("output /%s/", strings.TrimPrefix(w.Output.String(), "(?m)")))
+	}
+	return strings.Join(parts, " and ")
 }
 
 // EnsureConfigDir returns the lmux config directory path, creating it if needed.
@@ -55,43 +142,273 @@ func EnsureConfigDir() (string, error) {
 	return dir, nil
 }
 
-// ProjectFilePath returns the expected path for a project toml file.
+// ProjectFilePath returns the path of a project toml file: the first of the
+// config directory and the search_paths setting holding it, or the config
+// directory for a new project.
 func ProjectFilePath(name string) string {
+	file := fmt.Sprintf("%s.toml", name)
+	dirs := projectDirs()
+	for _, dir := range dirs {
+		path := filepath.Join(dir, file)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return filepath.Join(dirs[0], file)
+}
+
+// projectDirs returns the config directory followed by the search_paths
+// setting, expanded.
+func projectDirs() []string {
 	dir, _ := EnsureConfigDir()
-	return filepath.Join(dir, fmt.Sprintf("%s.toml", name))
+	dirs := []string{dir}
+	if settings, err := LoadSettings(); err == nil {
+		for _, d := range settings.SearchPaths {
+			dirs = append(dirs, ExpandPath(d))
+		}
+	}
+	return dirs
+}
+
+// reservedDirs are the subdirectories of the config directory that do not
+// hold projects.
+var reservedDirs = map[string]bool{"templates": true, "workspaces": true}
+
+// ListProjects returns the names of the project files in the config directory
+// and search paths, sorted. Files in subdirectories are named by their path,
+// like "work/api". A project found in several places is listed once.
+func ListProjects() ([]string, error) {
+	if _, err := EnsureConfigDir(); err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	var names []string
+	for i, dir := range projectDirs() {
+		err := filepath.WalkDir(dir, func(path string, e fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, _ := filepath.Rel(dir, path)
+			if e.IsDir() {
+				if path != dir && (strings.HasPrefix(e.Name(), ".") || i == 0 && reservedDirs[rel]) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !strings.HasSuffix(rel, ".toml") || rel == "settings.toml" {
+				return nil
+			}
+			name := filepath.ToSlash(strings.TrimSuffix(rel, ".toml"))
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+			return nil
+		})
+		// A missing search path should not hide the other projects
+		if err != nil && !(i > 0 && os.IsNotExist(err)) {
+			return nil, err
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// projectDir returns the project directory holding the file at path, or
+// the file's own directory outside them.
+func projectDir(path string) string {
+	for _, dir := range projectDirs() {
+		if rel, err := filepath.Rel(dir, path); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return dir
+		}
+	}
+	return filepath.Dir(path)
+}
+
+// projectName returns the name of the project file at path, relative to the
+// project directory holding it.
+func projectName(path string) string {
+	rel, _ := filepath.Rel(projectDir(path), path)
+	return filepath.ToSlash(strings.TrimSuffix(rel, ".toml"))
 }
 
 // LoadProject loads and parses a project by name from the config directory.
 func LoadProject(name string) (Project, error) {
+	return LoadProjectFile(ProjectFilePath(name))
+}
+
+// LoadProjectProfile loads a project by name with the named profile, or all
+// windows if profile is empty.
+func LoadProjectProfile(name, profile string) (Project, error) {
+	return LoadProjectFileFor(ProjectFilePath(name), CurrentPlatform(), profile)
+}
+
+// LoadProjectFile loads and parses the project file at path for the current
+// platform, filling unset values from the user's settings.
+func LoadProjectFile(path string) (Project, error) {
+	return LoadProjectFileFor(path, CurrentPlatform(), "")
+}
+
+// LoadProjectFileFor is LoadProjectFile with the overrides for platform and
+// the named profile.
+func LoadProjectFileFor(path string, platform Platform, profile string) (Project, error) {
 	var project Project
-	path := ProjectFilePath(name)
+	settings, err := LoadSettings()
+	if err != nil {
+		return project, err
+	}
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return project, err
 	}
-	if err := toml.Unmarshal(data, &project); err != nil {
+	decoder := toml.NewDecoder(bytes.NewReader(data))
+	if settings.Strict {
+		decoder.DisallowUnknownFields()
+	}
+	if err := decoder.Decode(&project); err != nil {
+		var strictErr *toml.StrictMissingError
+		if errors.As(err, &strictErr) {
+			return project, unknownKeysError{strictErr, " with strict set"}
+		}
+		return project, err
+	}
+	if err := project.applyOverrides(platform); err != nil {
 		return project, err
 	}
+	if err := project.applyProfile(profile, path); err != nil {
+		return project, err
+	}
+	settings.applyTo(&project)
+	project.platform = platform
 	if err := normalizeProject(&project); err != nil {
 		return project, err
 	}
 	return project, nil
 }
 
-// SaveSample writes a sample project file with provided name and workingDir.
-func SaveSample(name, workingDir string, force bool) (string, error) {
-	path := ProjectFilePath(name)
+// unknownKeysError reports the keys a strict load did not recognise.
+type unknownKeysError struct {
+	*toml.StrictMissingError
+	// reason follows "unknown keys" in the message, e.g. " with strict set".
+	reason string
+}
+
+func (e unknownKeysError) Error() string {
+	keys := make([]string, len(e.Errors))
+	for i, keyErr := range e.Errors {
+		line, _ := keyErr.Position()
+		keys[i] = fmt.Sprintf("%s (line %d)", strings.Join(keyErr.Key(), "."), line)
+	}
+	return "unknown keys" + e.reason + ": " + strings.Join(keys, ", ")
+}
+
+func (e unknownKeysError) Unwrap() error { return e.StrictMissingError }
+
+// ErrorLine returns the line of a project file a LoadProjectFile error points
+// at, or 0 if it does not point at one.
+func ErrorLine(err error) int {
+	var decodeErr *toml.DecodeError
+	if errors.As(err, &decodeErr) {
+		line, _ := decodeErr.Position()
+		return line
+	}
+	var strictErr *toml.StrictMissingError
+	if errors.As(err, &strictErr) && len(strictErr.Errors) > 0 {
+		line, _ := strictErr.Errors[0].Position()
+		return line
+	}
+	return 0
+}
+
+// DeleteProject removes a project file from the config directory.
+func DeleteProject(name string) error {
+	return os.Remove(ProjectFilePath(name))
+}
+
+// CopyProject copies project src to dst and returns the new path. The copy
+// is written to the project directory holding src, which may be a search
+// path, and its name field is set to dst so the two projects do not share a
+// session.
+func CopyProject(src, dst string, force bool) (string, error) {
+	srcPath := ProjectFilePath(src)
+	data, err := os.ReadFile(srcPath)
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(projectDir(srcPath), dst+".toml")
+	// A dst elsewhere in the search paths would shadow the copy, or be
+	// shadowed by it
+	if existing := ProjectFilePath(dst); existing != path {
+		if _, err := os.Stat(existing); err == nil {
+			return "", fmt.Errorf("project %s already exists: %s", dst, existing)
+		}
+	}
 	if !force {
 		if _, err := os.Stat(path); err == nil {
 			return "", fmt.Errorf("file exists: %s (use --force to overwrite)", path)
 		}
 	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, setProjectName(data, dst), 0o644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// RenameProject moves project src to dst within its project directory,
+// updating its name field, and returns the new path.
+func RenameProject(src, dst string) (string, error) {
+	srcPath := ProjectFilePath(src)
+	path, err := CopyProject(src, dst, false)
+	if err != nil {
+		return "", err
+	}
+	if err := os.Remove(srcPath); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+var nameLine = regexp.MustCompile(`(?m)^([ \t]*)name[ \t]*=.*$`)
+var tableHeader = regexp.MustCompile(`(?m)^[ \t]*\[`)
+
+// setProjectName rewrites the top-level name key of a project file, leaving
+// the rest of the file, comments included, untouched. Files without one
+// already default to their file name.
+func setProjectName(data []byte, name string) []byte {
+	top := len(data)
+	if loc := tableHeader.FindIndex(data); loc != nil {
+		top = loc[0]
+	}
+	// "$" would start a group reference in the replacement
+	head := nameLine.ReplaceAll(data[:top], []byte("${1}name = "+strings.ReplaceAll(tomlString(name), "$", "$$")))
+	return append(head, data[top:]...)
+}
+
+// SaveSample writes a sample project file with provided name and workingDir.
+func SaveSample(name, workingDir string, force bool) (string, error) {
 	content := strings.ReplaceAll(SampleTOML, "<%= name %>", name)
 	if workingDir == "" {
 		workingDir = "~/"
 	}
 	// Keep the path on a commented line so TOML stays valid
 	content = strings.ReplaceAll(content, "# <%= path %>", "# "+workingDir)
+	return SaveProject(name, content, force)
+}
+
+// SaveProject writes content as the project file for name and returns its path.
+func SaveProject(name, content string, force bool) (string, error) {
+	path := ProjectFilePath(name)
+	if !force {
+		if _, err := os.Stat(path); err == nil {
+			return "", fmt.Errorf("file exists: %s (use --force to overwrite)", path)
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
 	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
 		return "", err
 	}
@@ -107,19 +424,144 @@ func normalizeProject(p *Project) error {
 	if p.TmuxCommand == "" {
 		p.TmuxCommand = "tmux"
 	}
+	flags, err := shell.Split(p.TmuxOptions)
+	if err != nil {
+		return fmt.Errorf("tmux_options: %w", err)
+	}
+	for i, f := range flags {
+		// Expand ~ as the shell would have, e.g. in "-f ~/.tmux.conf"
+		if strings.HasPrefix(f, "~") {
+			flags[i] = ExpandPath(f)
+		}
+	}
+	p.TmuxFlags = flags
+
+	if p.PreWindowRaw != nil {
+		preWindow, err := parseCommands(p.PreWindowRaw)
+		if err != nil {
+			return fmt.Errorf("pre_window: %w", err)
+		}
+		p.PreWindow = preWindow
+	}
 
 	windows, err := parseWindows(p.WindowsRaw)
 	if err != nil {
 		return err
 	}
-	p.Windows = windows
+	generated, err := p.generatedWindows()
+	if err != nil {
+		return err
+	}
+	if p.Windows, err = p.profileWindows(mergeWindows(windows, generated)); err != nil {
+		return err
+	}
+	if err := p.applyWhen(); err != nil {
+		return err
+	}
+	for i := range p.Windows {
+		p.Windows[i].applyDefaults(p)
+	}
 
 	if len(p.Windows) == 0 {
 		return errors.New("project must have at least one window")
 	}
+	if _, err := p.WindowOrder(); err != nil {
+		return err
+	}
 	return nil
 }
 
+// HasDependencies reports whether any window declares depends_on.
+func (p Project) HasDependencies() bool {
+	for _, w := range p.Windows {
+		if len(w.DependsOn) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// WindowDependencies returns, for each window, the indexes of the windows it depends on.
+func (p Project) WindowDependencies() ([][]int, error) {
+	index := make(map[string]int, len(p.Windows))
+	for i, w := range p.Windows {
+		if _, dup := index[w.Name]; dup && w.Name != "" {
+			index[w.Name] = -1 // ambiguous
+			continue
+		}
+		index[w.Name] = i
+	}
+	deps := make([][]int, len(p.Windows))
+	for i, w := range p.Windows {
+		for _, name := range w.DependsOn {
+			j, ok := index[name]
+			switch {
+			case !ok || name == "":
+				return nil, fmt.Errorf("window %s depends on unknown window %q", w.Name, name)
+			case j < 0:
+				return nil, fmt.Errorf("window %s depends on %q, which names more than one window", w.Name, name)
+			case j == i:
+				return nil, fmt.Errorf("window %s depends on itself", w.Name)
+			}
+			deps[i] = append(deps[i], j)
+		}
+	}
+	return deps, nil
+}
+
+// WindowOrder returns window indexes ordered so that every window comes after
+// the windows it depends on, keeping the configured order otherwise. It fails
+// on unknown dependencies and cycles.
+func (p Project) WindowOrder() ([]int, error) {
+	deps, err := p.WindowDependencies()
+	if err != nil {
+		return nil, err
+	}
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make([]int, len(p.Windows))
+	order := make([]int, 0, len(p.Windows))
+	var stack []int
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch state[i] {
+		case visited:
+			return nil
+		case visiting:
+			// Report the cycle starting from the first window on it
+			var names []string
+			for k := len(stack) - 1; k >= 0; k-- {
+				names = append([]string{p.Windows[stack[k]].Name}, names...)
+				if stack[k] == i {
+					break
+				}
+			}
+			names = append(names, p.Windows[i].Name)
+			return fmt.Errorf("window dependency cycle: %s", strings.Join(names, " -> "))
+		}
+		state[i] = visiting
+		stack = append(stack, i)
+		for _, d := range deps[i] {
+			if err := visit(d); err != nil {
+				return err
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[i] = visited
+		order = append(order, i)
+		return nil
+	}
+	for i := range p.Windows {
+		if err := visit(i); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
 func parseWindows(raw []any) ([]Window, error) {
 	result := make([]Window, 0, len(raw))
 	for _, item := range raw {
@@ -159,6 +601,13 @@ func parseWindows(raw []any) ([]Window, error) {
 			if root, ok := v["root"].(string); ok {
 				win.Root = root
 			}
+			if cmdsRaw, ok := v["commands"]; ok {
+				cmds, err := parseCommands(cmdsRaw)
+				if err != nil {
+					return nil, fmt.Errorf("window %s: %w", name, err)
+				}
+				win.Commands = cmds
+			}
 			if panesRaw, ok := v["panes"]; ok {
 				panes, err := parsePanes(panesRaw)
 				if err != nil {
@@ -166,6 +615,27 @@ func parseWindows(raw []any) ([]Window, error) {
 				}
 				win.Panes = panes
 			}
+			if waitRaw, ok := v["wait_for"]; ok {
+				wait, err := parseWaitFor(waitRaw)
+				if err != nil {
+					return nil, fmt.Errorf("window %s: %w", name, err)
+				}
+				win.WaitFor = wait
+			}
+			if depsRaw, ok := v["depends_on"]; ok {
+				deps, err := parseCommands(depsRaw)
+				if err != nil {
+					return nil, fmt.Errorf("window %s: depends_on must be a window name or array of names", name)
+				}
+				win.DependsOn = deps
+			}
+			if whenRaw, ok := v["when"]; ok {
+				when, err := parseWhen(whenRaw)
+				if err != nil {
+					return nil, fmt.Errorf("window %s: %w", name, err)
+				}
+				win.When = when
+			}
 			// If top-level string command provided (e.g., { server: "rails s" }) that's handled above.
 		default:
 			return nil, fmt.Errorf("unsupported window value type: %T", value)
@@ -195,6 +665,14 @@ func parsePanes(raw any) ([]Pane, error) {
 			}
 			panes = append(panes, Pane{Commands: cmds})
 		case map[string]any:
+			if isStructuredPane(v) {
+				pane, err := parseStructuredPane(v)
+				if err != nil {
+					return nil, err
+				}
+				panes = append(panes, pane)
+				continue
+			}
 			// { title: commands }
 			if len(v) != 1 {
 				return nil, fmt.Errorf("pane entry must contain exactly one title, got %d", len(v))
@@ -229,6 +707,154 @@ func parsePanes(raw any) ([]Pane, error) {
 	return panes, nil
 }
 
+// isStructuredPane reports whether a pane table uses named keys such as
+// { commands = ..., wait_for = ..., when = ... } rather than the
+// { title = commands } form.
+func isStructuredPane(m map[string]any) bool {
+	_, hasCommands := m["commands"]
+	_, hasWait := m["wait_for"]
+	_, hasWhen := m["when"]
+	return hasCommands || hasWait || hasWhen
+}
+
+func parseStructuredPane(m map[string]any) (Pane, error) {
+	var pane Pane
+	for k, v := range m {
+		switch k {
+		case "title":
+			title, ok := v.(string)
+			if !ok {
+				return pane, fmt.Errorf("pane title must be a string, got %T", v)
+			}
+			pane.Title = title
+		case "commands":
+			cmds, err := parseCommands(v)
+			if err != nil {
+				return pane, fmt.Errorf("pane: %w", err)
+			}
+			pane.Commands = cmds
+		case "wait_for":
+			wait, err := parseWaitFor(v)
+			if err != nil {
+				return pane, fmt.Errorf("pane: %w", err)
+			}
+			pane.WaitFor = wait
+		case "when":
+			when, err := parseWhen(v)
+			if err != nil {
+				return pane, fmt.Errorf("pane: %w", err)
+			}
+			pane.When = when
+		default:
+			return pane, fmt.Errorf("unknown pane key %q", k)
+		}
+	}
+	return pane, nil
+}
+
+// parseCommands accepts a single command string or an array of them.
+func parseCommands(raw any) ([]string, error) {
+	switch v := raw.(type) {
+	case string:
+		if strings.TrimSpace(v) == "" {
+			return nil, nil
+		}
+		return []string{v}, nil
+	case []any:
+		cmds := make([]string, 0, len(v))
+		for _, c := range v {
+			if s, ok := c.(string); ok && strings.TrimSpace(s) != "" {
+				cmds = append(cmds, s)
+			}
+		}
+		return cmds, nil
+	default:
+		return nil, fmt.Errorf("commands must be a string or array, got %T", raw)
+	}
+}
+
+func parseWaitFor(raw any) (*WaitFor, error) {
+	m, ok := raw.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("wait_for must be a table, got %T", raw)
+	}
+	wait := &WaitFor{Host: "localhost", Timeout: DefaultWaitTimeout}
+	for k, v := range m {
+		switch k {
+		case "port":
+			port, ok := v.(int64)
+			if !ok || port <= 0 || port > 65535 {
+				return nil, fmt.Errorf("wait_for.port must be a port number, got %v", v)
+			}
+			wait.Port = int(port)
+		case "host":
+			host, ok := v.(string)
+			if !ok || strings.TrimSpace(host) == "" {
+				return nil, fmt.Errorf("wait_for.host must be a non-empty string, got %v", v)
+			}
+			wait.Host = host
+		case "file":
+			file, ok := v.(string)
+			if !ok || strings.TrimSpace(file) == "" {
+				return nil, fmt.Errorf("wait_for.file must be a non-empty string, got %v", v)
+			}
+			wait.File = file
+		case "command":
+			cmd, ok := v.(string)
+			if !ok || strings.TrimSpace(cmd) == "" {
+				return nil, fmt.Errorf("wait_for.command must be a non-empty string, got %v", v)
+			}
+			wait.Command = cmd
+		case "output":
+			pattern, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("wait_for.output must be a string, got %T", v)
+			}
+			// Match line by line, like grep, since panes hold many lines
+			re, err := regexp.Compile("(?m)" + pattern)
+			if err != nil {
+				return nil, fmt.Errorf("wait_for.output: %w", err)
+			}
+			wait.Output = re
+		case "timeout":
+			timeout, err := parseTimeout(v)
+			if err != nil {
+				return nil, err
+			}
+			wait.Timeout = timeout
+		default:
+			return nil, fmt.Errorf("unknown wait_for key %q", k)
+		}
+	}
+	if wait.Port == 0 && wait.File == "" && wait.Command == "" && wait.Output == nil {
+		return nil, errors.New("wait_for needs at least one of port, file, command or output")
+	}
+	return wait, nil
+}
+
+// parseTimeout accepts a duration string ("45s", "2m") or a number of seconds.
+func parseTimeout(v any) (time.Duration, error) {
+	var d time.Duration
+	switch t := v.(type) {
+	case string:
+		parsed, err := time.ParseDuration(t)
+		if err != nil {
+			return 0, fmt.Errorf("wait_for.timeout: %w", err)
+		}
+		d = parsed
+	case int64:
+		d = time.Duration(t) * time.Second
+	case float64:
+		d = time.Duration(t * float64(time.Second))
+	default:
+		return 0, fmt.Errorf("wait_for.timeout must be a duration or seconds, got %T", v)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("wait_for.timeout must be positive, got %v", v)
+	}
+	return d, nil
+}
+
 // ExpandPath expands ~ and environment variables in a path-like string.
 func ExpandPath(p string) string {
 	p = strings.TrimSpace(p)
@@ -1,6 +1,16 @@
 package config
 
-import "testing"
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+)
 
 func TestParseWindowsRejectsMultipleWindowNames(t *testing.T) {
 	_, err := parseWindows([]any{map[string]any{"editor": "nvim", "server": "go run ."}})
@@ -15,3 +25,836 @@ func TestParsePanesRejectsMultipleTitles(t *testing.T) {
 		t.Fatal("parsePanes accepted a pane entry with multiple titles")
 	}
 }
+
+func TestParseWindowsReadsWaitFor(t *testing.T) {
+	windows, err := parseWindows([]any{map[string]any{"server": map[string]any{
+		"commands": "go run .",
+		"wait_for": map[string]any{"port": int64(8080), "output": "listening", "timeout": "5s"},
+		"panes": []any{map[string]any{
+			"commands": []any{"tail -f log"},
+			"wait_for": map[string]any{"file": "tmp/ready"},
+		}},
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := windows[0]
+	if len(w.Commands) != 1 || w.Commands[0] != "go run ." {
+		t.Fatalf("window commands = %q, want [go run .]", w.Commands)
+	}
+	if w.WaitFor == nil || w.WaitFor.Port != 8080 || w.WaitFor.Timeout != 5*time.Second || !w.WaitFor.Output.MatchString("log\nlistening on :8080") {
+		t.Fatalf("window wait_for = %+v, want port 8080, 5s timeout and a line-anchored output pattern", w.WaitFor)
+	}
+	if p := w.Panes[0]; p.WaitFor == nil || p.WaitFor.File != "tmp/ready" || p.WaitFor.Timeout != DefaultWaitTimeout {
+		t.Fatalf("pane wait_for = %+v, want file tmp/ready with the default timeout", p.WaitFor)
+	}
+}
+
+func TestParseWaitForRejectsInvalidConditions(t *testing.T) {
+	for _, raw := range []map[string]any{
+		{},
+		{"port": int64(0)},
+		{"output": "("},
+		{"timeout": "soon", "file": "x"},
+		{"file": "x", "retries": int64(3)},
+	} {
+		if _, err := parseWaitFor(raw); err == nil {
+			t.Errorf("parseWaitFor(%v) accepted an invalid wait_for", raw)
+		}
+	}
+}
+
+func TestWindowOrderPlacesDependenciesFirst(t *testing.T) {
+	p := Project{Windows: []Window{
+		{Name: "e2e", DependsOn: []string{"server", "db"}},
+		{Name: "server", DependsOn: []string{"db"}},
+		{Name: "editor"},
+		{Name: "db"},
+	}}
+	order, err := p.WindowOrder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, i := range order {
+		names = append(names, p.Windows[i].Name)
+	}
+	if got, want := strings.Join(names, ","), "db,server,e2e,editor"; got != want {
+		t.Fatalf("WindowOrder() = %s, want %s", got, want)
+	}
+}
+
+func TestNormalizeProjectRejectsDependencyProblems(t *testing.T) {
+	for name, raw := range map[string][]any{
+		"cycle": {
+			map[string]any{"a": map[string]any{"depends_on": "b"}},
+			map[string]any{"b": map[string]any{"depends_on": []any{"a"}}},
+		},
+		"unknown": {
+			map[string]any{"a": map[string]any{"depends_on": "missing"}},
+		},
+		"self": {
+			map[string]any{"a": map[string]any{"depends_on": "a"}},
+		},
+	} {
+		p := Project{WindowsRaw: raw}
+		if err := normalizeProject(&p); err == nil {
+			t.Errorf("%s: normalizeProject accepted invalid depends_on", name)
+		}
+	}
+}
+
+func TestSetProjectNameRewritesOnlyTopLevelName(t *testing.T) {
+	in := "# api project\nname = \"api\" # session\nroot = \"~/api\"\n\n[[windows]]\nname = \"server\"\n"
+	want := "# api project\nname = \"backend\"\nroot = \"~/api\"\n\n[[windows]]\nname = \"server\"\n"
+	if got := string(setProjectName([]byte(in), "backend")); got != want {
+		t.Fatalf("setProjectName() = %q, want %q", got, want)
+	}
+	noName := "root = \"~/api\"\n[[windows]]\nname = \"server\"\n"
+	if got := string(setProjectName([]byte(noName), "backend")); got != noName {
+		t.Fatalf("setProjectName() changed a file without a name: %q", got)
+	}
+}
+
+func TestRenderedStringsAreValidTOML(t *testing.T) {
+	windows := []TemplateWindow{{Name: "bell\a", Command: "printf '\a\v' && echo \"done\" \U0001F680"}}
+	content := RenderTemplate("name = \"<%= name %>\"\n<%= windows %>", "api", t.TempDir(), windows)
+	var project Project
+	if err := toml.Unmarshal([]byte(content), &project); err != nil {
+		t.Fatalf("rendered project does not parse: %v\n%s", err, content)
+	}
+	if got := project.WindowsRaw[0].(map[string]any)["bell\a"]; got != windows[0].Command {
+		t.Fatalf("window command = %q, want %q", got, windows[0].Command)
+	}
+
+	renamed := setProjectName([]byte("name = \"api\"\n"), "a$1\tb")
+	if err := toml.Unmarshal(renamed, &project); err != nil || project.Name != "a$1\tb" {
+		t.Fatalf("setProjectName() = %q (%v), want the name kept verbatim", renamed, err)
+	}
+}
+
+func TestRenameProjectMovesFile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	if err := os.WriteFile(ProjectFilePath("api"), []byte("name = \"api\"\n\n[[windows]]\nserver = \"go run .\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CopyProject("api", "api", false); err == nil {
+		t.Fatal("CopyProject overwrote an existing project")
+	}
+	if _, err := RenameProject("api", "backend"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(ProjectFilePath("api")); !os.IsNotExist(err) {
+		t.Fatalf("old project still exists: %v", err)
+	}
+	project, err := LoadProject("backend")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if project.Name != "backend" {
+		t.Fatalf("renamed project name = %q, want backend", project.Name)
+	}
+}
+
+func TestListProjectsIncludesNestedNames(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	for _, name := range []string{"api", "work/web", "work/infra/db"} {
+		if _, err := SaveProject(name, "[[windows]]\nshell = \"\"\n\n[profiles.light]\nsession_suffix = \"-light\"\n", false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dir, _ := EnsureConfigDir()
+	for _, path := range []string{"templates/mine.toml", "workspaces/day.toml", ".git/config.toml"} {
+		path = filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	names, err := ListProjects()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(names, ","), "api,work/infra/db,work/web"; got != want {
+		t.Fatalf("projects = %s, want %s", got, want)
+	}
+	project, err := LoadProjectProfile("work/web", "light")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if project.Name != "work/web-light" {
+		t.Fatalf("profile session = %q, want work/web-light", project.Name)
+	}
+}
+
+func TestRenameProjectStaysInSearchPath(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	shared := filepath.Join(home, "shared")
+	if err := os.MkdirAll(shared, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveSettings(Settings{SearchPaths: []string{"~/shared"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(shared, "api.toml"), []byte("[[windows]]\nserver = \"go run .\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(ProjectFilePath("web"), []byte("[[windows]]\nweb = \"\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	path, err := CopyProject("api", "api-copy", false)
+	if want := filepath.Join(shared, "api-copy.toml"); err != nil || path != want {
+		t.Fatalf("CopyProject() = %q, %v, want %q", path, err, want)
+	}
+	path, err = RenameProject("api", "backend")
+	if want := filepath.Join(shared, "backend.toml"); err != nil || path != want {
+		t.Fatalf("RenameProject() = %q, %v, want %q", path, err, want)
+	}
+	if _, err := os.Stat(filepath.Join(shared, "api.toml")); !os.IsNotExist(err) {
+		t.Fatalf("old project still exists: %v", err)
+	}
+	if _, err := CopyProject("backend", "web", true); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("copy over a project in another directory: err = %v", err)
+	}
+}
+
+func TestDetectProjectReadsScriptsAndProcfile(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"package.json":   `{"scripts": {"dev": "vite", "start": "node server.js", "test:watch": "vitest", "build": "vite build"}}`,
+		"pnpm-lock.yaml": "",
+		"Procfile.dev":   "# local processes\nweb: bin/web\nworker: bin/worker --verbose\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	d := DetectProject(dir)
+	if d.Template != "node" {
+		t.Fatalf("template = %q, want node", d.Template)
+	}
+	var got []string
+	for _, w := range d.Windows {
+		got = append(got, w.Name+"="+w.Command)
+	}
+	want := "dev=pnpm dev,test-watch=pnpm test:watch,web=bin/web,worker=bin/worker --verbose"
+	if strings.Join(got, ",") != want {
+		t.Fatalf("windows = %q, want %q", strings.Join(got, ","), want)
+	}
+}
+
+func TestDetectedWindowsDoNotRepeatNames(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	dir := filepath.Join(home, "src", "app")
+	files := map[string]string{
+		"bin/rails": "",
+		"manage.py": "",
+		"Procfile":  "server: bin/rails server -p 5000\nworker: bin/jobs\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	d := DetectProject(dir)
+	var got []string
+	for _, w := range d.Windows {
+		got = append(got, w.Name)
+	}
+	if strings.Join(got, ",") != "server,worker" {
+		t.Fatalf("windows = %v, want server once", got)
+	}
+
+	tmpl, err := LoadTemplate(d.Template)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SaveProject("app", RenderTemplate(tmpl.Content, "app", dir, d.Windows), false); err != nil {
+		t.Fatal(err)
+	}
+	project, err := LoadProject("app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := map[string]bool{}
+	for _, w := range project.Windows {
+		if seen[w.Name] {
+			t.Fatalf("window %s appears twice in %+v", w.Name, project.Windows)
+		}
+		seen[w.Name] = true
+		if w.Name == "server" && w.Commands[0] != "bin/rails server" {
+			t.Fatalf("server runs %q, want the template's command", w.Commands[0])
+		}
+	}
+	if !seen["worker"] {
+		t.Fatalf("windows = %+v, want the Procfile worker added", project.Windows)
+	}
+}
+
+func TestBuiltinTemplatesRenderValidProjects(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	templates, err := Templates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(templates) != 5 {
+		t.Fatalf("found %d templates, want the 5 built-ins", len(templates))
+	}
+	windows := []TemplateWindow{{Name: "web", Command: `bin/web --name "x"`}, {Name: "test:watch", Command: "vitest"}}
+	for _, tmpl := range templates {
+		content := RenderTemplate(tmpl.Content, "app", filepath.Join(home, "src", "app"), windows)
+		if _, err := SaveProject("app", content, true); err != nil {
+			t.Fatal(err)
+		}
+		project, err := LoadProject("app")
+		if err != nil {
+			t.Fatalf("%s template: %v\n%s", tmpl.Name, err, content)
+		}
+		if project.Root != "~/src/app" || len(project.Windows) < 3 {
+			t.Fatalf("%s template: root %q with %d windows", tmpl.Name, project.Root, len(project.Windows))
+		}
+	}
+}
+
+func TestUserTemplateOverridesBuiltin(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	dir, err := TemplatesDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "go.toml"), []byte("name = \"<%= name %>\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tmpl, err := LoadTemplate("go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tmpl.Source != filepath.Join(dir, "go.toml") {
+		t.Fatalf("go template source = %q, want the user template", tmpl.Source)
+	}
+	if _, err := LoadTemplate("cobol"); err == nil || !strings.Contains(err.Error(), "available: docker-compose, go") {
+		t.Fatalf("unknown template error = %v", err)
+	}
+}
+
+func TestProcfileAndComposeWindowsMergeWithDeclared(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	root := filepath.Join(home, "app")
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"Procfile.dev":       "web: bin/rails server\ncss: bin/rails tailwindcss:watch\n",
+		"docker-compose.yml": "version: '3'\nservices:\n  postgres:\n    image: postgres\n  redis:\n    image: redis\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	project := "root = \"~/app\"\nprocfile = \"Procfile.dev\"\ncompose = \"docker-compose.yml\"\n\n[[windows]]\nweb = \"bin/dev\"\n"
+	if _, err := SaveProject("app", project, false); err != nil {
+		t.Fatal(err)
+	}
+	p, err := LoadProject("app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, w := range p.Windows {
+		got = append(got, w.Name+"="+strings.Join(w.Commands, ";"))
+	}
+	want := []string{
+		"web=bin/dev",
+		"css=bin/rails tailwindcss:watch",
+		"compose=docker compose -f docker-compose.yml up -d",
+		"postgres=docker compose -f docker-compose.yml logs -f postgres",
+		"redis=docker compose -f docker-compose.yml logs -f redis",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("windows:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if p.Windows[2].WaitFor == nil || p.Windows[3].DependsOn[0] != ComposeWindow {
+		t.Fatal("service windows do not wait for the compose window")
+	}
+}
+
+func TestGeneratedProjectReferencesFile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	if err := os.WriteFile(filepath.Join(home, "Procfile"), []byte("web: ./serve\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	file, err := FindProcessFile(home, ProcfileNames)
+	if err != nil || file != "Procfile" {
+		t.Fatalf("FindProcessFile() = %q, %v", file, err)
+	}
+	if _, err := SaveProject("gen", GeneratedProject("gen", home, "procfile", file), false); err != nil {
+		t.Fatal(err)
+	}
+	p, err := LoadProject("gen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Windows) != 1 || p.Windows[0].Name != "web" || p.Root != "~" {
+		t.Fatalf("generated project = root %q, windows %+v", p.Root, p.Windows)
+	}
+}
+
+func TestErrorLinePointsAtSyntaxError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "broken.toml")
+	if err := os.WriteFile(path, []byte("name = \"api\"\n\n[[windows]\nshell = \"\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := LoadProjectFile(path)
+	if err == nil {
+		t.Fatal("expected a parse error")
+	}
+	if line := ErrorLine(err); line != 3 {
+		t.Fatalf("ErrorLine = %d, want 3", line)
+	}
+}
+
+func TestSettingsFillProjectDefaults(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	var settings Settings
+	for key, values := range map[string][]string{
+		"attach":       {"false"},
+		"root":         {"~/code"},
+		"pre_window":   {"source .env"},
+		"layout":       {"main-vertical"},
+		"tmux_options": {"-f ~/.tmux.conf -L 'my socket'"},
+	} {
+		if err := settings.Set(key, values...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := SaveSettings(settings); err != nil {
+		t.Fatal(err)
+	}
+	content := "name = \"api\"\nroot = \"~/api\"\n\n[[windows]]\nserver = \"go run .\"\n\n[[windows]]\nedit = { panes = [\"vim\", \"\"] }\n"
+	if _, err := SaveProject("api", content, false); err != nil {
+		t.Fatal(err)
+	}
+
+	project, err := LoadProject("api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if project.Attach == nil || *project.Attach {
+		t.Fatalf("attach = %v, want the settings default false", project.Attach)
+	}
+	if project.Root != "~/api" {
+		t.Fatalf("root = %q, want the project's own root", project.Root)
+	}
+	if got := strings.Join(project.Windows[0].Commands, "; "); got != "source .env; go run ." {
+		t.Fatalf("window commands = %q, want pre_window first", got)
+	}
+	edit := project.Windows[1]
+	if edit.Layout != "main-vertical" || edit.Panes[1].Commands[0] != "source .env" {
+		t.Fatalf("edit window = %+v, want the default layout and pre_window in every pane", edit)
+	}
+	want := []string{"-f", filepath.Join(home, ".tmux.conf"), "-L", "my socket"}
+	if !slices.Equal(project.TmuxFlags, want) {
+		t.Fatalf("tmux flags = %q, want %q", project.TmuxFlags, want)
+	}
+
+	p := Project{TmuxOptions: "-f 'unterminated", WindowsRaw: []any{map[string]any{"shell": ""}}}
+	if err := normalizeProject(&p); err == nil || !strings.Contains(err.Error(), "tmux_options") {
+		t.Fatalf("normalizeProject() error = %v, want tmux_options rejected", err)
+	}
+}
+
+func TestSampleOptionsLoadWhenUncommented(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	if err := SaveSettings(Settings{Strict: true}); err != nil {
+		t.Fatal(err)
+	}
+	option := regexp.MustCompile(`(?m)^# (\w+ = [^#]*?)\s*(#.*)?$`)
+	content := option.ReplaceAllString(SampleTOML, "$1")
+	if content == SampleTOML {
+		t.Fatal("sample has no commented options")
+	}
+	if _, err := SaveProject("sample", content, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadProject("sample"); err != nil {
+		t.Fatalf("sample with its options uncommented does not load: %v", err)
+	}
+}
+
+func TestSettingsStrictAndSearchPaths(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	shared := filepath.Join(home, "shared")
+	if err := os.MkdirAll(shared, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(shared, "web.toml"), []byte("rooot = \"~\"\n\n[[windows]]\nshell = \"\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	settings := Settings{SearchPaths: []string{"~/shared", "~/missing"}}
+	if err := SaveSettings(settings); err != nil {
+		t.Fatal(err)
+	}
+
+	names, err := ListProjects()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(names, ",") != "web" {
+		t.Fatalf("projects = %v, want web from the search path", names)
+	}
+	if _, err := LoadProject("web"); err != nil {
+		t.Fatalf("non-strict load: %v", err)
+	}
+
+	settings.Strict = true
+	if err := SaveSettings(settings); err != nil {
+		t.Fatal(err)
+	}
+	_, err = LoadProject("web")
+	if err == nil || !strings.Contains(err.Error(), "rooot (line 1)") {
+		t.Fatalf("strict load error = %v, want the unknown key named", err)
+	}
+	if line := ErrorLine(err); line != 1 {
+		t.Fatalf("ErrorLine = %d, want 1", line)
+	}
+}
+
+func TestOverridesMergeForPlatform(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	content := `name = "api"
+root = "~/src/api"
+
+[[windows]]
+server = "go run ."
+
+[[windows]]
+db = "docker compose up db"
+
+[overrides."os:linux"]
+root = "/srv/api"
+
+[overrides."os:wsl"]
+root = "/mnt/c/src/api"
+
+[overrides."host:devbox"]
+root = "/data/api"
+[[overrides."host:devbox".windows]]
+db = "pg_ctl start"
+[[overrides."host:devbox".windows]]
+gpu = "nvidia-smi -l"
+`
+	path, err := SaveProject("api", content, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		platform Platform
+		root     string
+		windows  string
+	}{
+		{Platform{Host: "laptop", OS: "darwin"}, "~/src/api", "server=go run .|db=docker compose up db"},
+		{Platform{Host: "laptop", OS: "wsl"}, "/mnt/c/src/api", "server=go run .|db=docker compose up db"},
+		{Platform{Host: "devbox.example.com", OS: "linux"}, "/data/api", "server=go run .|db=pg_ctl start|gpu=nvidia-smi -l"},
+	}
+	for _, tt := range tests {
+		project, err := LoadProjectFileFor(path, tt.platform, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var windows []string
+		for _, w := range project.Windows {
+			windows = append(windows, w.Name+"="+strings.Join(w.Commands, ";"))
+		}
+		if project.Root != tt.root || strings.Join(windows, "|") != tt.windows {
+			t.Errorf("%+v: root %q, windows %q; want %q, %q", tt.platform, project.Root, windows, tt.root, tt.windows)
+		}
+	}
+
+	if _, err := SaveProject("bad", "[[windows]]\na = \"\"\n\n[overrides.\"arch:arm64\"]\nroot = \"/\"\n", false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadProject("bad"); err == nil || !strings.Contains(err.Error(), "host:NAME or os:NAME") {
+		t.Fatalf("LoadProject error = %v, want the selector rejected", err)
+	}
+}
+
+func TestProfileSelectsWindowsAndSuffixesSession(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	content := `[[windows]]
+editor = "nvim"
+
+[[windows]]
+db = "docker compose up db"
+
+[[windows]]
+server = { commands = "rails s", depends_on = ["db"] }
+
+[[windows]]
+tests = "bin/guard"
+
+[profiles.light]
+windows = ["tests", "editor"]
+session_suffix = "-light"
+attach = false
+
+[profiles.web]
+windows = ["server"]
+`
+	if _, err := SaveProject("app", content, false); err != nil {
+		t.Fatal(err)
+	}
+
+	project, err := LoadProjectProfile("app", "light")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, w := range project.Windows {
+		names = append(names, w.Name)
+	}
+	if strings.Join(names, ",") != "editor,tests" {
+		t.Fatalf("windows = %v, want editor,tests in project order", names)
+	}
+	if project.Name != "app-light" || *project.Attach {
+		t.Fatalf("name %q, attach %v; want app-light, false", project.Name, *project.Attach)
+	}
+
+	if _, err := LoadProjectProfile("app", "web"); err == nil || !strings.Contains(err.Error(), "depends on db") {
+		t.Fatalf("web profile error = %v, want the left-out dependency named", err)
+	}
+	if _, err := LoadProjectProfile("app", "full"); err == nil || !strings.Contains(err.Error(), "available: light, web") {
+		t.Fatalf("unknown profile error = %v", err)
+	}
+}
+
+func TestWhenDropsWindowsAndPanes(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("LMUX_TEST_CI", "true")
+	root := filepath.Join(home, "src")
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "docker-compose.yml"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	content := `root = "` + root + `"
+startup_window = "gpu"
+
+[[windows]]
+db = { commands = "docker compose up db", when = { exists = "docker-compose.yml", command = "touch ran" } }
+
+[[windows]]
+cache = { commands = "redis-server", when = { exists = "redis.conf" } }
+
+[[windows]]
+server = { commands = "rails s", depends_on = ["db", "cache"], when = { env = "LMUX_TEST_CI=true" } }
+
+[[windows]]
+gpu = { commands = "nvidia-smi -l", when = { os = "linux" } }
+
+[[windows]]
+logs = { panes = [
+  "tail -f log/development.log",
+  { commands = "journalctl -f", when = { command = "false" } },
+  { commands = "tail -f log/ci.log", when = { env = "LMUX_TEST_CI" } },
+] }
+`
+	path, err := SaveProject("app", content, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	project, err := LoadProjectFileFor(path, Platform{OS: "darwin"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "ran")); err != nil {
+		t.Fatalf("loading did not run the when command in the window root: %v", err)
+	}
+	var names []string
+	for _, w := range project.Windows {
+		names = append(names, w.Name)
+	}
+	if strings.Join(names, ",") != "db,server,logs" {
+		t.Fatalf("windows = %v, want db,server,logs", names)
+	}
+	if deps := project.Windows[1].DependsOn; len(deps) != 1 || deps[0] != "db" {
+		t.Fatalf("server depends_on = %v, want the dropped cache removed", deps)
+	}
+	if panes := project.Windows[2].Panes; len(panes) != 2 || panes[1].Commands[0] != "tail -f log/ci.log" {
+		t.Fatalf("logs panes = %+v, want the journalctl pane dropped", panes)
+	}
+	if project.StartupWindow != "" {
+		t.Fatalf("startup_window = %q, want the dropped gpu window unset", project.StartupWindow)
+	}
+
+	if project, err = LoadProjectFileFor(path, Platform{OS: "wsl"}, ""); err != nil {
+		t.Fatal(err)
+	}
+	if len(project.Windows) != 4 || project.Windows[2].Name != "gpu" || project.StartupWindow != "gpu" {
+		t.Fatalf("windows on wsl = %+v, want gpu kept as the startup window", project.Windows)
+	}
+
+	if _, err := parseWindows([]any{map[string]any{"db": map[string]any{"when": map[string]any{"arch": "arm64"}}}}); err == nil {
+		t.Fatal("parseWindows accepted an unknown when key")
+	}
+}
+
+func TestWhenDropsWindowWithoutPanesAndRenumbersStartupPane(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("LMUX_TEST_UNSET", "")
+	content := `startup_window = "logs"
+startup_pane = 2
+
+[[windows]]
+gpu = { panes = [
+  { commands = "nvidia-smi -l", when = { command = "false" } },
+  { commands = "nvtop", when = { os = "windows" } },
+] }
+
+[[windows]]
+server = { commands = "rails s", depends_on = ["gpu"] }
+
+[[windows]]
+logs = { panes = [
+  { commands = "journalctl -f", when = { command = "false" } },
+  "tail -f log/development.log",
+  "tail -f log/test.log",
+] }
+`
+	path, err := SaveProject("app", content, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	project, err := LoadProjectFileFor(path, Platform{OS: "linux"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(project.Windows) != 2 || project.Windows[0].Name != "server" {
+		t.Fatalf("windows = %+v, want gpu dropped with all its panes", project.Windows)
+	}
+	if deps := project.Windows[0].DependsOn; len(deps) != 0 {
+		t.Fatalf("server depends_on = %v, want the dropped gpu removed", deps)
+	}
+	if project.StartupWindow != "logs" || project.StartupPane != 1 {
+		t.Fatalf("startup = %s.%d, want logs.1 after the journalctl pane is dropped", project.StartupWindow, project.StartupPane)
+	}
+
+	content = `startup_window = "logs"
+startup_pane = 3
+
+[[windows]]
+logs = { panes = [
+  { commands = "journalctl -f", when = { command = "false" } },
+  "tail -f log/development.log",
+  { commands = "tail -f log/ci.log", when = { env = "LMUX_TEST_UNSET" } },
+  "tail -f log/test.log",
+] }
+`
+	if path, err = SaveProject("app", content, true); err != nil {
+		t.Fatal(err)
+	}
+	if project, err = LoadProjectFileFor(path, Platform{OS: "linux"}, ""); err != nil {
+		t.Fatal(err)
+	}
+	if panes := project.Windows[0].Panes; project.StartupPane != 1 || panes[project.StartupPane].Commands[0] != "tail -f log/test.log" {
+		t.Fatalf("startup pane = %d of %+v, want 1, test.log, after two earlier panes are dropped", project.StartupPane, panes)
+	}
+
+	content = strings.Replace(content, `"tail -f log/test.log"`, `{ commands = "tail -f log/test.log", when = { command = "false" } }`, 1)
+	if path, err = SaveProject("app", content, true); err != nil {
+		t.Fatal(err)
+	}
+	if project, err = LoadProjectFileFor(path, Platform{OS: "linux"}, ""); err != nil {
+		t.Fatal(err)
+	}
+	if project.StartupPane != 0 || len(project.Windows[0].Panes) != 1 {
+		t.Fatalf("startup pane = %d with panes %+v, want 0 after the startup pane is dropped", project.StartupPane, project.Windows[0].Panes)
+	}
+
+	content = "[[windows]]\ngpu = { commands = \"nvtop\", when = { command = \"false\" } }\n"
+	if path, err = SaveProject("app", content, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadProjectFileFor(path, Platform{OS: "linux"}, ""); err == nil {
+		t.Fatal("loaded a project whose windows were all left out")
+	}
+}
+
+func TestLoadWorkspace(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	dir, err := WorkspacesDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"day":     "[[projects]]\nname = \"api\"\nprofile = \"light\"\n\n[[projects]]\nname = \"web\"\nskip = [\"tests\"]\n",
+		"primary": "primary = \"infra\"\n\n[[projects]]\nname = \"api\"\n",
+		"empty":   "jobs = 2\n",
+		"typo":    "[[projects]]\nname = \"api\"\nprofiles = \"light\"\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name+".toml"), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ws, err := LoadWorkspace("day")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ws.Primary != "api" || ws.Jobs != DefaultWorkspaceJobs || len(ws.Projects) != 2 || ws.Projects[0].Profile != "light" || ws.Projects[1].Skip[0] != "tests" {
+		t.Fatalf("workspace = %+v, want api primary with the default jobs", ws)
+	}
+	for name, want := range map[string]string{
+		"primary": `primary "infra"`,
+		"empty":   "at least one project",
+		"typo":    "profiles",
+		"night":   "available: day, empty, primary, typo",
+	} {
+		if _, err := LoadWorkspace(name); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("LoadWorkspace(%q) error = %v, want it to mention %q", name, err, want)
+		}
+	}
+
+	// A project file outside the workspaces directory must not load as one
+	if _, err := SaveProject("outside", "[[projects]]\nname = \"api\"\n", false); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"../outside", "..\\outside", filepath.Join(home, ".config", "lmux", "outside"), "", " "} {
+		if _, err := LoadWorkspace(name); err == nil || !strings.Contains(err.Error(), "invalid workspace name") {
+			t.Errorf("LoadWorkspace(%q) error = %v, want the name rejected", name, err)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/sbcinnovation/lmux/internal/shell"
+)
+
+// ComposeWindow names the window that brings compose services up; the
+// per-service log windows depend on it.
+const ComposeWindow = "compose"
+
+// composeTimeout bounds how long service windows wait for containers.
+const composeTimeout = 2 * time.Minute
+
+// ProcfileNames and ComposeFileNames are the files `lmux init --from` looks
+// for, in order of preference.
+var (
+	ProcfileNames    = []string{"Procfile.dev", "Procfile"}
+	ComposeFileNames = []string{"compose.yaml", "compose.yml", "docker-compose.yaml", "docker-compose.yml"}
+)
+
+// generatedWindows expands the project's procfile and compose files into
+// windows. Relative paths are resolved against the project root, which is
+// also where the generated commands run.
+func (p Project) generatedWindows() ([]Window, error) {
+	var windows []Window
+	if p.Procfile != "" {
+		entries, err := ParseProcfile(p.projectPath(p.Procfile))
+		if err != nil {
+			return nil, fmt.Errorf("procfile: %w", err)
+		}
+		for _, e := range entries {
+			windows = append(windows, Window{Name: e.Name, Commands: []string{e.Command}})
+		}
+	}
+	if p.Compose != "" {
+		services, err := composeServices(p.projectPath(p.Compose))
+		if err != nil {
+			return nil, fmt.Errorf("compose: %w", err)
+		}
+		compose := "docker compose -f " + shell.Quote(p.Compose)
+		windows = append(windows, Window{
+			Name:     ComposeWindow,
+			Commands: []string{compose + " up -d"},
+			WaitFor:  &WaitFor{Command: compose + " ps --status running --quiet | grep -q .", Timeout: composeTimeout},
+		})
+		for _, svc := range services {
+			windows = append(windows, Window{
+				Name:      svc,
+				Commands:  []string{compose + " logs -f " + shell.Quote(svc)},
+				DependsOn: []string{ComposeWindow},
+			})
+		}
+	}
+	return windows, nil
+}
+
+func (p Project) projectPath(path string) string {
+	path = ExpandPath(path)
+	if filepath.IsAbs(path) || p.Root == "" {
+		return path
+	}
+	return filepath.Join(ExpandPath(p.Root), path)
+}
+
+// mergeWindows appends generated windows whose names are not already
+// declared, so a declared window overrides a generated one.
+func mergeWindows(declared, generated []Window) []Window {
+	names := map[string]bool{}
+	for _, w := range declared {
+		names[w.Name] = true
+	}
+	for _, w := range generated {
+		if !names[w.Name] {
+			declared = append(declared, w)
+			names[w.Name] = true
+		}
+	}
+	return declared
+}
+
+// composeServices returns the service names of a compose file in the order
+// they are declared.
+func composeServices(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s: not a compose file", path)
+	}
+	top := doc.Content[0]
+	for i := 0; i+1 < len(top.Content); i += 2 {
+		if top.Content[i].Value != "services" {
+			continue
+		}
+		services := top.Content[i+1]
+		var names []string
+		for j := 0; j+1 < len(services.Content); j += 2 {
+			names = append(names, services.Content[j].Value)
+		}
+		return names, nil
+	}
+	return nil, fmt.Errorf("%s: no services", path)
+}
+
+// FindProcessFile returns the first of names present in dir.
+func FindProcessFile(dir string, names []string) (string, error) {
+	for _, name := range names {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("none of %s found in %s", strings.Join(names, ", "), dir)
+}
+
+// GeneratedProject renders a project file whose windows all come from file,
+// referenced through key ("procfile" or "compose").
+func GeneratedProject(name, dir, key, file string) string {
+	content := "# <%= path %>\n\nname = \"<%= name %>\"\nroot = \"<%= root %>\"\n" + key + " = \"" + tomlEscape(file) + "\"\n"
+	return RenderTemplate(content, name, dir, nil)
+}
@@ -0,0 +1,144 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// Platform selects the overrides that apply to a project: those for its
+// host name and operating system.
+type Platform struct {
+	Host string
+	// OS is a runtime.GOOS value, or "wsl" on the Windows Subsystem for
+	// Linux, which also matches "linux".
+	OS string
+}
+
+// CurrentPlatform returns the platform lmux runs on.
+func CurrentPlatform() Platform {
+	host, _ := os.Hostname()
+	p := Platform{Host: host, OS: runtime.GOOS}
+	if p.OS == "linux" && isWSL() {
+		p.OS = "wsl"
+	}
+	return p
+}
+
+func isWSL() bool {
+	if os.Getenv("WSL_DISTRO_NAME") != "" {
+		return true
+	}
+	release, err := os.ReadFile("/proc/sys/kernel/osrelease")
+	return err == nil && strings.Contains(strings.ToLower(string(release)), "microsoft")
+}
+
+// matches reports whether an overrides selector such as "host:devbox" or
+// "os:linux" applies to the platform. Host names match in full or up to the
+// first dot.
+func (p Platform) matches(selector string) (bool, error) {
+	kind, value, _ := strings.Cut(selector, ":")
+	switch kind {
+	case "host":
+		host := strings.ToLower(p.Host)
+		short, _, _ := strings.Cut(host, ".")
+		value = strings.ToLower(value)
+		return value != "" && (value == host || value == short), nil
+	case "os":
+		return p.isOS(value), nil
+	default:
+		return false, fmt.Errorf("overrides.%q: selector must be host:NAME or os:NAME", selector)
+	}
+}
+
+// isOS reports whether the platform runs the named OS.
+func (p Platform) isOS(name string) bool {
+	return name == p.OS || name == "linux" && p.OS == "wsl"
+}
+
+// applyOverrides merges the overrides matching platform onto the project:
+// os tables first, then host tables, so the more specific wins. Keys set in
+// an override replace the project's, and its windows replace the project's
+// windows of the same name or are added after them.
+func (p *Project) applyOverrides(platform Platform) error {
+	selectors := make([]string, 0, len(p.Overrides))
+	for selector, o := range p.Overrides {
+		if len(o.Overrides) > 0 {
+			return fmt.Errorf("overrides.%q: overrides cannot be nested", selector)
+		}
+		ok, err := platform.matches(selector)
+		if err != nil {
+			return err
+		}
+		if ok {
+			selectors = append(selectors, selector)
+		}
+	}
+	sort.Slice(selectors, func(i, j int) bool {
+		// "host:" sorts before "os:", but hosts should apply last
+		if hi, hj := strings.HasPrefix(selectors[i], "host:"), strings.HasPrefix(selectors[j], "host:"); hi != hj {
+			return hj
+		}
+		return selectors[i] < selectors[j]
+	})
+	for _, selector := range selectors {
+		p.merge(p.Overrides[selector])
+	}
+	return nil
+}
+
+// merge copies the values set in o over p's.
+func (p *Project) merge(o Project) {
+	for _, f := range []struct{ dst, src *string }{
+		{&p.Name, &o.Name},
+		{&p.Root, &o.Root},
+		{&p.TmuxCommand, &o.TmuxCommand},
+		{&p.TmuxOptions, &o.TmuxOptions},
+		{&p.StartupWindow, &o.StartupWindow},
+		{&p.Layout, &o.Layout},
+		{&p.Procfile, &o.Procfile},
+		{&p.Compose, &o.Compose},
+	} {
+		if *f.src != "" {
+			*f.dst = *f.src
+		}
+	}
+	if o.Attach != nil {
+		p.Attach = o.Attach
+	}
+	if o.StartupPane != 0 {
+		p.StartupPane = o.StartupPane
+	}
+	if o.PreWindowRaw != nil {
+		p.PreWindowRaw = o.PreWindowRaw
+	}
+	for _, w := range o.WindowsRaw {
+		replaced := false
+		name := rawWindowName(w)
+		for i, base := range p.WindowsRaw {
+			if name != "" && rawWindowName(base) == name {
+				p.WindowsRaw[i] = w
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			p.WindowsRaw = append(p.WindowsRaw, w)
+		}
+	}
+}
+
+// rawWindowName returns the name of an unparsed window entry, or "" if it is
+// not a single-key table.
+func rawWindowName(raw any) string {
+	m, ok := raw.(map[string]any)
+	if !ok || len(m) != 1 {
+		return ""
+	}
+	for name := range m {
+		return name
+	}
+	return ""
+}
@@ -0,0 +1,201 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Profile is a named variant of a project, such as one starting only some
+// of its windows.
+type Profile struct {
+	// Windows names the windows to start, which keep the project's order.
+	// Empty keeps every window.
+	Windows []string `toml:"windows,omitempty"`
+	// SessionSuffix is appended to the session name so the profile can run
+	// beside the full project, e.g. "-light".
+	SessionSuffix string `toml:"session_suffix,omitempty"`
+
+	// Values replacing the project's.
+	Root          string `toml:"root,omitempty"`
+	Attach        *bool  `toml:"attach,omitempty"`
+	StartupWindow string `toml:"startup_window,omitempty"`
+	StartupPane   int    `toml:"startup_pane,omitempty"`
+	PreWindowRaw  any    `toml:"pre_window,omitempty"`
+	Layout        string `toml:"layout,omitempty"`
+}
+
+// ProfileNames returns the project's profile names, sorted.
+func (p Project) ProfileNames() []string {
+	names := make([]string, 0, len(p.Profiles))
+	for name := range p.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// applyProfile selects the named profile of the project loaded from path,
+// merging its values and suffixing the session name. The window selection is
+// applied once windows are parsed.
+func (p *Project) applyProfile(name, path string) error {
+	if name == "" {
+		return nil
+	}
+	profile, ok := p.Profiles[name]
+	if !ok {
+		if len(p.Profiles) == 0 {
+			return fmt.Errorf("unknown profile %q: the project has no profiles", name)
+		}
+		return fmt.Errorf("unknown profile %q (available: %s)", name, strings.Join(p.ProfileNames(), ", "))
+	}
+	p.Profile = name
+	p.merge(Project{
+		Root:          profile.Root,
+		Attach:        profile.Attach,
+		StartupWindow: profile.StartupWindow,
+		StartupPane:   profile.StartupPane,
+		PreWindowRaw:  profile.PreWindowRaw,
+		Layout:        profile.Layout,
+	})
+	if profile.SessionSuffix != "" {
+		if p.Name == "" {
+			p.Name = projectName(path)
+		}
+		p.Name += profile.SessionSuffix
+	}
+	return nil
+}
+
+// profileWindows keeps the windows the selected profile names.
+func (p Project) profileWindows(windows []Window) ([]Window, error) {
+	if p.Profile == "" || len(p.Profiles[p.Profile].Windows) == 0 {
+		return windows, nil
+	}
+	kept, err := selectWindows(windows, p.Profiles[p.Profile].Windows, nil)
+	if err != nil {
+		return nil, fmt.Errorf("profile %s: %w", p.Profile, err)
+	}
+	return kept, nil
+}
+
+// FilterWindows keeps the windows named in only, or all if it is empty,
+// except those named in skip. A startup window that is left out is unset.
+func (p *Project) FilterWindows(only, skip []string) error {
+	if len(only) == 0 && len(skip) == 0 {
+		return nil
+	}
+	kept, err := selectWindows(p.Windows, only, skip)
+	if err != nil {
+		return err
+	}
+	if len(kept) == 0 {
+		return errors.New("no windows left to start")
+	}
+	p.Windows = kept
+	if p.StartupWindow != "" && !keepsWindow(kept, p.StartupWindow) {
+		p.StartupWindow = ""
+		p.StartupPane = 0
+	}
+	return nil
+}
+
+// SetStartupWindow makes the named window the one selected once the session
+// is built, in place of startup_window and startup_pane.
+func (p *Project) SetStartupWindow(name string) error {
+	if !keepsWindow(p.Windows, name) {
+		return unknownWindowError(name, p.Windows)
+	}
+	p.StartupWindow = name
+	p.StartupPane = 0
+	return nil
+}
+
+func unknownWindowError(name string, windows []Window) error {
+	names := make([]string, len(windows))
+	for i, w := range windows {
+		names[i] = w.Name
+	}
+	if suggestion := closestName(name, names); suggestion != "" {
+		return fmt.Errorf("unknown window %q (did you mean %q?)", name, suggestion)
+	}
+	return fmt.Errorf("unknown window %q (windows: %s)", name, strings.Join(names, ", "))
+}
+
+// selectWindows keeps the windows named in only, or all if it is empty,
+// except those named in skip, in their original order. It rejects unknown
+// names and windows whose dependencies are left out.
+func selectWindows(windows []Window, only, skip []string) ([]Window, error) {
+	for _, name := range append(append([]string(nil), only...), skip...) {
+		if !keepsWindow(windows, name) {
+			return nil, unknownWindowError(name, windows)
+		}
+	}
+
+	var kept []Window
+	for _, w := range windows {
+		if (len(only) == 0 || contains(only, w.Name)) && !contains(skip, w.Name) {
+			kept = append(kept, w)
+		}
+	}
+	for _, w := range kept {
+		for _, dep := range w.DependsOn {
+			if !keepsWindow(kept, dep) {
+				return nil, fmt.Errorf("window %s depends on %s, which is left out", w.Name, dep)
+			}
+		}
+	}
+	return kept, nil
+}
+
+func keepsWindow(windows []Window, name string) bool {
+	for _, w := range windows {
+		if w.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// closestName returns the candidate nearest to name by edit distance, or ""
+// if none is close enough to be a likely typo.
+func closestName(name string, candidates []string) string {
+	best, bestDistance := "", len(name)/2+2
+	for _, c := range candidates {
+		if d := editDistance(strings.ToLower(name), strings.ToLower(c)); d < bestDistance {
+			best, bestDistance = c, d
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
@@ -7,22 +7,16 @@ const SampleTOML = `# <%= path %>
 name = "<%= name %>"
 root = "~/"
 
-# Optional tmux socket (not yet supported)
-# socket_name = "foo"
+# Project hooks (on_project_start, on_project_stop, ...) are not supported
+# yet; pre_window covers per-pane setup.
 
-# Project hooks (not yet supported in lmux)
-# on_project_start = "command"
-# on_project_first_start = "command"
-# on_project_restart = "command"
-# on_project_exit = "command"
-# on_project_stop = "command"
-
-# pre_window = "echo 'setup env'"   # not yet supported
-# tmux_options = "-f ~/.tmux.conf"  # supported (parsed only)
+# pre_window = "echo 'setup env'"    # supported (string or array, run in every pane)
+# layout = "main-vertical"           # supported (for windows whose panes set none)
+# tmux_options = "-f ~/.tmux.conf"   # supported (e.g. "-L foo" for a separate socket)
 # tmux_command = "tmux"              # supported
 # startup_window = "1"               # supported (by index or name)
-# startup_pane = 1                    # supported (by index)
-# attach = true                       # supported
+# startup_pane = 1                   # supported (by index)
+# attach = true                      # supported
 
 [[windows]]
 editor.layout = "main-vertical"
@@ -3,8 +3,11 @@ package config
 
 import (
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 
 	"github.com/pelletier/go-toml/v2"
 )
@@ -12,6 +15,222 @@ import (
 // Settings holds user-level configuration for lmux.
 type Settings struct {
 	Editor string `toml:"editor,omitempty"`
+	// EditIn is where the editor opens when lmux runs inside tmux: "pane"
+	// (the default, in the current pane), "window" or "popup".
+	EditIn string `toml:"edit_in,omitempty"`
+	// TmuxBackend is how sessions are built: "exec" (the default, a tmux
+	// process per command) or "control", one `tmux -C` connection.
+	TmuxBackend string `toml:"tmux_backend,omitempty"`
+
+	// Defaults for projects that leave these unset.
+	TmuxCommand string   `toml:"tmux_command,omitempty"`
+	TmuxOptions string   `toml:"tmux_options,omitempty"`
+	Attach      *bool    `toml:"attach,omitempty"`
+	Root        string   `toml:"root,omitempty"`
+	PreWindow   []string `toml:"pre_window,omitempty"`
+	Layout      string   `toml:"layout,omitempty"`
+
+	// Strict rejects project files with unknown top-level keys.
+	Strict bool `toml:"strict,omitempty"`
+	// SearchPaths are directories searched for project files after the
+	// config directory.
+	SearchPaths []string `toml:"search_paths,omitempty"`
+}
+
+// SettingKey describes a key of settings.toml.
+type SettingKey struct {
+	Name  string
+	Usage string
+	// List is set for keys holding several values.
+	List bool
+
+	get func(*Settings) any
+	// set receives nil to unset the key.
+	set func(*Settings, []string) error
+}
+
+// SettingKeys returns the keys `lmux config` manages, in the order it lists
+// them.
+func SettingKeys() []SettingKey {
+	return []SettingKey{
+		stringKey("editor", "editor command, e.g. \"nvim +{line}\"", func(s *Settings) *string { return &s.Editor }, nil),
+		stringKey("edit_in", "where the editor opens inside tmux: pane, window or popup", func(s *Settings) *string { return &s.EditIn }, oneOf("pane", "window", "popup")),
+		stringKey("tmux_backend", "how sessions are built: exec (a process per command) or control (one tmux -C connection)", func(s *Settings) *string { return &s.TmuxBackend }, oneOf("exec", "control")),
+		stringKey("tmux_command", "default tmux binary for projects", func(s *Settings) *string { return &s.TmuxCommand }, nil),
+		stringKey("tmux_options", "default tmux options for projects", func(s *Settings) *string { return &s.TmuxOptions }, nil),
+		{
+			Name:  "attach",
+			Usage: "whether start attaches by default",
+			get: func(s *Settings) any {
+				if s.Attach == nil {
+					return nil
+				}
+				return *s.Attach
+			},
+			set: func(s *Settings, values []string) error {
+				if values == nil {
+					s.Attach = nil
+					return nil
+				}
+				v, err := strconv.ParseBool(values[0])
+				if err != nil {
+					return fmt.Errorf("attach must be true or false, got %q", values[0])
+				}
+				s.Attach = &v
+				return nil
+			},
+		},
+		stringKey("root", "default project root", func(s *Settings) *string { return &s.Root }, nil),
+		listKey("pre_window", "commands run in every pane before its own", func(s *Settings) *[]string { return &s.PreWindow }),
+		stringKey("layout", "default layout for windows with panes", func(s *Settings) *string { return &s.Layout }, nil),
+		{
+			Name:  "strict",
+			Usage: "reject unknown top-level keys in project files",
+			get:   func(s *Settings) any { return s.Strict },
+			set: func(s *Settings, values []string) error {
+				if values == nil {
+					s.Strict = false
+					return nil
+				}
+				v, err := strconv.ParseBool(values[0])
+				if err != nil {
+					return fmt.Errorf("strict must be true or false, got %q", values[0])
+				}
+				s.Strict = v
+				return nil
+			},
+		},
+		listKey("search_paths", "directories searched for projects after the config directory", func(s *Settings) *[]string { return &s.SearchPaths }),
+	}
+}
+
+func stringKey(name, usage string, field func(*Settings) *string, validate func(string) error) SettingKey {
+	return SettingKey{
+		Name:  name,
+		Usage: usage,
+		get: func(s *Settings) any {
+			if v := *field(s); v != "" {
+				return v
+			}
+			return nil
+		},
+		set: func(s *Settings, values []string) error {
+			if values == nil {
+				*field(s) = ""
+				return nil
+			}
+			if validate != nil {
+				if err := validate(values[0]); err != nil {
+					return fmt.Errorf("%s: %w", name, err)
+				}
+			}
+			*field(s) = values[0]
+			return nil
+		},
+	}
+}
+
+func listKey(name, usage string, field func(*Settings) *[]string) SettingKey {
+	return SettingKey{
+		Name:  name,
+		Usage: usage,
+		List:  true,
+		get: func(s *Settings) any {
+			if v := *field(s); len(v) > 0 {
+				return v
+			}
+			return nil
+		},
+		set: func(s *Settings, values []string) error {
+			*field(s) = values
+			return nil
+		},
+	}
+}
+
+func oneOf(allowed ...string) func(string) error {
+	return func(v string) error {
+		for _, a := range allowed {
+			if v == a {
+				return nil
+			}
+		}
+		return fmt.Errorf("must be one of %s, got %q", strings.Join(allowed, ", "), v)
+	}
+}
+
+func settingKey(name string) (SettingKey, error) {
+	keys := SettingKeys()
+	names := make([]string, len(keys))
+	for i, k := range keys {
+		if k.Name == name {
+			return k, nil
+		}
+		names[i] = k.Name
+	}
+	return SettingKey{}, fmt.Errorf("unknown setting %q (available: %s)", name, strings.Join(names, ", "))
+}
+
+// Get returns the value of the named setting: a string, bool or []string,
+// or nil if it is unset.
+func (s Settings) Get(name string) (any, error) {
+	key, err := settingKey(name)
+	if err != nil {
+		return nil, err
+	}
+	return key.get(&s), nil
+}
+
+// Set parses and stores the value of the named setting. List settings take
+// any number of values; the others exactly one.
+func (s *Settings) Set(name string, values ...string) error {
+	key, err := settingKey(name)
+	if err != nil {
+		return err
+	}
+	if !key.List && len(values) != 1 {
+		return fmt.Errorf("%s takes exactly one value", name)
+	}
+	if values == nil {
+		values = []string{}
+	}
+	return key.set(s, values)
+}
+
+// Unset clears the named setting.
+func (s *Settings) Unset(name string) error {
+	key, err := settingKey(name)
+	if err != nil {
+		return err
+	}
+	return key.set(s, nil)
+}
+
+// applyTo fills the values p leaves unset with the settings' defaults.
+func (s Settings) applyTo(p *Project) {
+	if p.TmuxCommand == "" {
+		p.TmuxCommand = s.TmuxCommand
+	}
+	if p.TmuxOptions == "" {
+		p.TmuxOptions = s.TmuxOptions
+	}
+	if p.Attach == nil && s.Attach != nil {
+		attach := *s.Attach
+		p.Attach = &attach
+	}
+	if p.Root == "" {
+		p.Root = s.Root
+	}
+	if p.PreWindowRaw == nil && len(s.PreWindow) > 0 {
+		raw := make([]any, len(s.PreWindow))
+		for i, c := range s.PreWindow {
+			raw[i] = c
+		}
+		p.PreWindowRaw = raw
+	}
+	if p.Layout == "" {
+		p.Layout = s.Layout
+	}
 }
 
 // settingsFilePath returns the path to the settings TOML file.
@@ -42,7 +261,7 @@ func LoadSettings() (Settings, error) {
 		return s, nil
 	}
 	if err := toml.Unmarshal(data, &s); err != nil {
-		return s, err
+		return s, fmt.Errorf("%s: %w", path, err)
 	}
 	return s, nil
 }
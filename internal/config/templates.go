@@ -0,0 +1,320 @@
+package config
+
+import (
+	"bufio"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+//go:embed templates/*.toml
+var builtinTemplates embed.FS
+
+// Template is a project file skeleton used by `lmux init`. Its content may
+// use the placeholders <%= name %>, <%= root %>, <%= path %> and
+// <%= windows %>, the last being replaced by windows detected in the project
+// directory.
+type Template struct {
+	Name string
+	// Source is "builtin" or the path of a user template.
+	Source  string
+	Content string
+}
+
+// TemplatesDir returns the directory holding user templates.
+func TemplatesDir() (string, error) {
+	dir, err := EnsureConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "templates"), nil
+}
+
+// Templates returns the built-in and user templates sorted by name. A user
+// template replaces the built-in one of the same name.
+func Templates() ([]Template, error) {
+	byName := map[string]Template{}
+	entries, err := builtinTemplates.ReadDir("templates")
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		data, err := builtinTemplates.ReadFile("templates/" + e.Name())
+		if err != nil {
+			return nil, err
+		}
+		name := strings.TrimSuffix(e.Name(), ".toml")
+		byName[name] = Template{Name: name, Source: "builtin", Content: string(data)}
+	}
+
+	dir, err := TemplatesDir()
+	if err != nil {
+		return nil, err
+	}
+	userEntries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, e := range userEntries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".toml") {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		name := strings.TrimSuffix(e.Name(), ".toml")
+		byName[name] = Template{Name: name, Source: path, Content: string(data)}
+	}
+
+	templates := make([]Template, 0, len(byName))
+	for _, t := range byName {
+		templates = append(templates, t)
+	}
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Name < templates[j].Name })
+	return templates, nil
+}
+
+// LoadTemplate returns the named template.
+func LoadTemplate(name string) (Template, error) {
+	templates, err := Templates()
+	if err != nil {
+		return Template{}, err
+	}
+	names := make([]string, len(templates))
+	for i, t := range templates {
+		if t.Name == name {
+			return t, nil
+		}
+		names[i] = t.Name
+	}
+	return Template{}, fmt.Errorf("unknown template %q (available: %s)", name, strings.Join(names, ", "))
+}
+
+// TemplateWindow is a single-command window detected in a project directory.
+type TemplateWindow struct {
+	Name    string
+	Command string
+}
+
+// Detection is what DetectProject found in a directory.
+type Detection struct {
+	// Template is the built-in template matching the project, or "" if none.
+	Template string
+	Windows  []TemplateWindow
+}
+
+// templateMarkers maps built-in templates to the files identifying them, in
+// order of precedence.
+var templateMarkers = []struct {
+	template string
+	files    []string
+}{
+	{"rails", []string{"bin/rails", "config/application.rb"}},
+	{"node", []string{"package.json"}},
+	{"python", []string{"pyproject.toml", "requirements.txt", "setup.py", "manage.py"}},
+	{"go", []string{"go.mod"}},
+	{"docker-compose", []string{"compose.yaml", "compose.yml", "docker-compose.yaml", "docker-compose.yml"}},
+}
+
+// DetectProject guesses a template for dir from the files in it and collects
+// windows for the processes it declares: package.json scripts, a Django
+// manage.py and Procfile entries.
+func DetectProject(dir string) Detection {
+	var d Detection
+	for _, m := range templateMarkers {
+		if d.Template == "" && anyExists(dir, m.files...) {
+			d.Template = m.template
+		}
+	}
+	if anyExists(dir, "package.json") {
+		d.Windows = append(d.Windows, packageScriptWindows(dir)...)
+	}
+	if anyExists(dir, "manage.py") {
+		d.Windows = append(d.Windows, TemplateWindow{Name: "server", Command: "python manage.py runserver"})
+	}
+	for _, name := range []string{"Procfile.dev", "Procfile"} {
+		if windows, err := ParseProcfile(filepath.Join(dir, name)); err == nil {
+			d.Windows = append(d.Windows, windows...)
+			break
+		}
+	}
+	d.Windows = uniqueWindows(d.Windows, nil)
+	return d
+}
+
+// uniqueWindows drops the windows named like an earlier one or one in taken,
+// so the first window of a name wins as in mergeWindows.
+func uniqueWindows(windows []TemplateWindow, taken map[string]bool) []TemplateWindow {
+	seen := map[string]bool{}
+	for name := range taken {
+		seen[name] = true
+	}
+	var kept []TemplateWindow
+	for _, w := range windows {
+		if !seen[w.Name] {
+			seen[w.Name] = true
+			kept = append(kept, w)
+		}
+	}
+	return kept
+}
+
+// devScripts are the package.json scripts worth a window, in order.
+var devScripts = []string{"dev", "start", "serve", "watch", "test:watch", "storybook"}
+
+// packageScriptWindows turns long-running package.json scripts into windows,
+// run with the package manager whose lockfile is present.
+func packageScriptWindows(dir string) []TemplateWindow {
+	data, err := os.ReadFile(filepath.Join(dir, "package.json"))
+	if err != nil {
+		return nil
+	}
+	var pkg struct {
+		Scripts map[string]string `json:"scripts"`
+	}
+	if json.Unmarshal(data, &pkg) != nil {
+		return nil
+	}
+	run := "npm run "
+	switch {
+	case anyExists(dir, "pnpm-lock.yaml"):
+		run = "pnpm "
+	case anyExists(dir, "yarn.lock"):
+		run = "yarn "
+	case anyExists(dir, "bun.lockb", "bun.lock"):
+		run = "bun run "
+	}
+	var windows []TemplateWindow
+	for _, script := range devScripts {
+		if _, ok := pkg.Scripts[script]; !ok {
+			continue
+		}
+		// "start" usually runs the production build when "dev" exists
+		if _, hasDev := pkg.Scripts["dev"]; script == "start" && hasDev {
+			continue
+		}
+		windows = append(windows, TemplateWindow{Name: strings.ReplaceAll(script, ":", "-"), Command: run + script})
+	}
+	return windows
+}
+
+// ParseProcfile reads the "name: command" entries of a Procfile.
+func ParseProcfile(path string) ([]TemplateWindow, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var windows []TemplateWindow
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, command, ok := strings.Cut(line, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("%s: invalid entry %q", path, line)
+		}
+		windows = append(windows, TemplateWindow{Name: strings.TrimSpace(name), Command: strings.TrimSpace(command)})
+	}
+	return windows, scanner.Err()
+}
+
+func anyExists(dir string, names ...string) bool {
+	for _, name := range names {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// RenderTemplate fills a template's placeholders for a project called name
+// rooted at dir. Detected windows named like one the template declares are
+// left out.
+func RenderTemplate(content, name, dir string, windows []TemplateWindow) string {
+	root := dir
+	if home, err := os.UserHomeDir(); err == nil && home != "" {
+		if rel, err := filepath.Rel(home, dir); err == nil && !strings.HasPrefix(rel, "..") {
+			root = filepath.ToSlash(filepath.Join("~", rel))
+		}
+	}
+	render := func(windows string) string {
+		return strings.NewReplacer(
+			"<%= name %>", tomlEscape(name),
+			"<%= root %>", tomlEscape(root),
+			"<%= path %>", dir,
+			"<%= windows %>", windows,
+		).Replace(content)
+	}
+	// Windows the template declares itself win over detected ones
+	declared := map[string]bool{}
+	var p Project
+	if toml.Unmarshal([]byte(render("")), &p) == nil {
+		if windows, err := parseWindows(p.WindowsRaw); err == nil {
+			for _, w := range windows {
+				declared[w.Name] = true
+			}
+		}
+	}
+	var w strings.Builder
+	for _, win := range uniqueWindows(windows, declared) {
+		fmt.Fprintf(&w, "\n[[windows]]\n%s = %s\n", tomlKey(win.Name), tomlString(win.Command))
+	}
+	return render(w.String())
+}
+
+var bareKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+func tomlKey(s string) string {
+	if bareKey.MatchString(s) {
+		return s
+	}
+	return tomlString(s)
+}
+
+// tomlString quotes s as a TOML basic string.
+func tomlString(s string) string {
+	return `"` + tomlEscape(s) + `"`
+}
+
+// tomlEscape escapes s for use inside a TOML basic string. Unlike Go's
+// quoting, it only uses the escapes TOML defines.
+func tomlEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '\\':
+			b.WriteString(`\\`)
+		case '"':
+			b.WriteString(`\"`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04X`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	return b.String()
+}
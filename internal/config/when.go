@@ -0,0 +1,165 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// whenTimeout bounds a when command, which runs every time the project loads.
+const whenTimeout = 5 * time.Second
+
+// When holds the conditions under which a window or pane is part of the
+// project. All given conditions must hold.
+type When struct {
+	// Env names a variable that must be set and non-empty, or NAME=VALUE.
+	Env string
+	// Exists is a path, relative to the window root, that must exist.
+	Exists string
+	// Command must exit 0; it runs in the window root.
+	Command string
+	// OS must match the platform, as in "os:" overrides.
+	OS string
+}
+
+func parseWhen(raw any) (*When, error) {
+	m, ok := raw.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("when must be a table, got %T", raw)
+	}
+	when := &When{}
+	for k, v := range m {
+		s, ok := v.(string)
+		if !ok || strings.TrimSpace(s) == "" {
+			return nil, fmt.Errorf("when.%s must be a non-empty string, got %v", k, v)
+		}
+		switch k {
+		case "env":
+			when.Env = s
+		case "exists":
+			when.Exists = s
+		case "command":
+			when.Command = s
+		case "os":
+			when.OS = s
+		default:
+			return nil, fmt.Errorf("unknown when key %q", k)
+		}
+	}
+	if *when == (When{}) {
+		return nil, errors.New("when needs at least one of env, exists, command or os")
+	}
+	return when, nil
+}
+
+// holds reports whether every condition holds on platform, with dir as the
+// directory relative paths and commands start from.
+func (w When) holds(platform Platform, dir string) bool {
+	if w.Env != "" {
+		name, value, hasValue := strings.Cut(w.Env, "=")
+		if v := os.Getenv(name); hasValue && v != value || !hasValue && v == "" {
+			return false
+		}
+	}
+	if w.Exists != "" {
+		path := ExpandPath(w.Exists)
+		if !filepath.IsAbs(path) && dir != "" {
+			path = filepath.Join(dir, path)
+		}
+		if _, err := os.Stat(path); err != nil {
+			return false
+		}
+	}
+	if w.OS != "" && !platform.isOS(w.OS) {
+		return false
+	}
+	if w.Command != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), whenTimeout)
+		defer cancel()
+		cmd := exec.CommandContext(ctx, "sh", "-c", w.Command)
+		if runtime.GOOS == "windows" {
+			cmd = exec.CommandContext(ctx, "cmd", "/C", w.Command)
+		}
+		cmd.Dir = dir
+		if err := cmd.Run(); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// applyWhen drops the windows and panes whose when conditions fail, and the
+// windows left without panes, along with dependencies on the dropped
+// windows. A startup window that is dropped is unset, and the startup pane
+// is renumbered past the panes dropped before it.
+func (p *Project) applyWhen() error {
+	platform := p.platform
+	if platform == (Platform{}) {
+		platform = CurrentPlatform()
+	}
+	var kept []Window
+	dropped := map[string]bool{}
+	for _, w := range p.Windows {
+		dir := w.Root
+		if strings.TrimSpace(dir) == "" {
+			dir = p.Root
+		}
+		dir = ExpandPath(dir)
+		if w.When != nil && !w.When.holds(platform, dir) {
+			dropped[w.Name] = true
+			continue
+		}
+		w.When = nil
+		if len(w.Panes) > 0 {
+			var panes []Pane
+			startup := w.Name == p.StartupWindow
+			// Compare against the configured index, not the one being renumbered
+			startupPane := p.StartupPane
+			for i, pane := range w.Panes {
+				if pane.When == nil || pane.When.holds(platform, dir) {
+					pane.When = nil
+					panes = append(panes, pane)
+					continue
+				}
+				if startup && i == startupPane {
+					p.StartupPane = 0
+				} else if startup && i < startupPane {
+					p.StartupPane--
+				}
+			}
+			if len(panes) == 0 {
+				dropped[w.Name] = true
+				continue
+			}
+			w.Panes = panes
+		}
+		kept = append(kept, w)
+	}
+	if len(kept) == 0 {
+		return errors.New("no window's when conditions hold")
+	}
+	p.Windows = kept
+	if len(dropped) == 0 {
+		return nil
+	}
+	for i, w := range kept {
+		var deps []string
+		for _, dep := range w.DependsOn {
+			if !dropped[dep] {
+				deps = append(deps, dep)
+			}
+		}
+		kept[i].DependsOn = deps
+	}
+	if p.StartupWindow != "" && dropped[p.StartupWindow] {
+		p.StartupWindow = ""
+		p.StartupPane = 0
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// DefaultWorkspaceJobs is how many projects of a workspace start at once
+// unless it sets jobs.
+const DefaultWorkspaceJobs = 4
+
+// Workspace is a group of projects started together, read from
+// ~/.config/lmux/workspaces/<name>.toml.
+type Workspace struct {
+	Name string `toml:"-"`
+	// Primary is the project attached once all have started. Empty means
+	// the first.
+	Primary string `toml:"primary,omitempty"`
+	// Jobs is how many projects start at once.
+	Jobs     int                `toml:"jobs,omitempty"`
+	Projects []WorkspaceProject `toml:"projects"`
+}
+
+// WorkspaceProject is a project of a workspace, with the options start
+// would otherwise take as flags.
+type WorkspaceProject struct {
+	Name    string   `toml:"name"`
+	Profile string   `toml:"profile,omitempty"`
+	Root    string   `toml:"root,omitempty"`
+	Only    []string `toml:"only,omitempty"`
+	Skip    []string `toml:"skip,omitempty"`
+}
+
+// WorkspacesDir returns the directory holding workspace files.
+func WorkspacesDir() (string, error) {
+	dir, err := EnsureConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "workspaces"), nil
+}
+
+// ListWorkspaces returns the names of the workspace files, sorted.
+func ListWorkspaces() ([]string, error) {
+	dir, err := WorkspacesDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".toml") {
+			names = append(names, strings.TrimSuffix(e.Name(), ".toml"))
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// LoadWorkspace reads and checks the named workspace. Workspace files sit
+// directly in WorkspacesDir, so names with path separators are rejected.
+func LoadWorkspace(name string) (Workspace, error) {
+	var ws Workspace
+	if strings.TrimSpace(name) == "" || strings.ContainsAny(name, `/\`) {
+		return ws, fmt.Errorf("invalid workspace name %q", name)
+	}
+	dir, err := WorkspacesDir()
+	if err != nil {
+		return ws, err
+	}
+	path := filepath.Join(dir, name+".toml")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			names, _ := ListWorkspaces()
+			if len(names) == 0 {
+				return ws, fmt.Errorf("unknown workspace %q: no workspaces in %s", name, dir)
+			}
+			return ws, fmt.Errorf("unknown workspace %q (available: %s)", name, strings.Join(names, ", "))
+		}
+		return ws, err
+	}
+	decoder := toml.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&ws); err != nil {
+		var strictErr *toml.StrictMissingError
+		if errors.As(err, &strictErr) {
+			err = unknownKeysError{strictErr, ""}
+		}
+		return ws, fmt.Errorf("%s: %w", path, err)
+	}
+	ws.Name = name
+	if err := ws.check(); err != nil {
+		return ws, fmt.Errorf("%s: %w", path, err)
+	}
+	if ws.Primary == "" {
+		ws.Primary = ws.Projects[0].Name
+	}
+	if ws.Jobs == 0 {
+		ws.Jobs = DefaultWorkspaceJobs
+	}
+	return ws, nil
+}
+
+func (ws Workspace) check() error {
+	if len(ws.Projects) == 0 {
+		return errors.New("workspace must list at least one project")
+	}
+	if ws.Jobs < 0 {
+		return fmt.Errorf("jobs must be positive, got %d", ws.Jobs)
+	}
+	seen := map[string]bool{}
+	for i, p := range ws.Projects {
+		if strings.TrimSpace(p.Name) == "" {
+			return fmt.Errorf("projects[%d] has no name", i)
+		}
+		key := p.Name + "\x00" + p.Profile
+		if seen[key] {
+			return fmt.Errorf("project %s is listed twice", p.Name)
+		}
+		seen[key] = true
+	}
+	if ws.Primary != "" {
+		for _, p := range ws.Projects {
+			if p.Name == ws.Primary {
+				return nil
+			}
+		}
+		return fmt.Errorf("primary %q is not one of the workspace's projects", ws.Primary)
+	}
+	return nil
+}
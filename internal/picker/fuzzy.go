@@ -0,0 +1,80 @@
+// Package picker implements the interactive fuzzy finder lmux shows when a
+// command needs a project name and none was given.
+package picker
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Match reports whether every rune of pattern appears in text in order,
+// ignoring case, and scores the match: consecutive runs and matches at the
+// start of words score higher. An empty pattern matches everything.
+func Match(pattern, text string) (int, bool) {
+	p := []rune(strings.ToLower(pattern))
+	if len(p) == 0 {
+		return 0, true
+	}
+	t := []rune(text)
+	// Greedy matching from the first occurrence can miss a better run later
+	// on ("api" in "backend-api"), so try every start and keep the best.
+	best, found := 0, false
+	for start := range t {
+		if unicode.ToLower(t[start]) != p[0] {
+			continue
+		}
+		if score, ok := matchFrom(p, t, start); ok && (!found || score > best) {
+			best, found = score, true
+		}
+	}
+	if !found {
+		return 0, false
+	}
+	// Prefer shorter candidates among equal matches
+	return best*100 - len(t), true
+}
+
+// matchFrom greedily matches p against t starting at t[start].
+func matchFrom(p, t []rune, start int) (int, bool) {
+	score, pi, prev := 0, 0, -2
+	for ti := start; ti < len(t) && pi < len(p); ti++ {
+		if unicode.ToLower(t[ti]) != p[pi] {
+			continue
+		}
+		switch {
+		case ti == prev+1:
+			score += 5 // continues a run
+		case ti == 0 || !unicode.IsLetter(t[ti-1]) && !unicode.IsDigit(t[ti-1]):
+			score += 3 // starts a word
+		default:
+			score++
+		}
+		prev = ti
+		pi++
+	}
+	return score, pi == len(p)
+}
+
+// Filter returns the items whose labels match pattern, best matches first.
+// Items with equal scores keep their original order.
+func Filter(items []Item, pattern string) []Item {
+	type scored struct {
+		item  Item
+		score int
+	}
+	var matches []scored
+	for _, it := range items {
+		if score, ok := Match(pattern, it.Label); ok {
+			matches = append(matches, scored{it, score})
+		}
+	}
+	if pattern != "" {
+		sort.SliceStable(matches, func(i, j int) bool { return matches[i].score > matches[j].score })
+	}
+	result := make([]Item, len(matches))
+	for i, m := range matches {
+		result[i] = m.item
+	}
+	return result
+}
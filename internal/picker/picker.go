@@ -0,0 +1,200 @@
+package picker
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/sbcinnovation/lmux/internal/tui"
+)
+
+// ErrCanceled is returned when the user leaves the picker without choosing.
+var ErrCanceled = errors.New("no project selected")
+
+// Item is a choice shown in the picker.
+type Item struct {
+	Label string
+	// Running marks items with a live tmux session.
+	Running bool
+	// Value identifies the item to the caller; it defaults to Label.
+	Value string
+	// Preview returns lines describing the item. It is called lazily for the
+	// highlighted item and may be nil.
+	Preview func() []string
+}
+
+// Model holds the picker state independently of the terminal.
+type Model struct {
+	Prompt   string
+	items    []Item
+	query    string
+	matches  []Item
+	cursor   int
+	previews map[string][]string
+}
+
+// NewModel returns a picker over items with an empty query.
+func NewModel(prompt string, items []Item) *Model {
+	m := &Model{Prompt: prompt, items: items, previews: map[string][]string{}}
+	m.refilter()
+	return m
+}
+
+// Query returns the current filter text.
+func (m *Model) Query() string { return m.query }
+
+// Matches returns the items matching the query, best first.
+func (m *Model) Matches() []Item { return m.matches }
+
+// Selected returns the highlighted item, if any.
+func (m *Model) Selected() (Item, bool) {
+	if len(m.matches) == 0 {
+		return Item{}, false
+	}
+	return m.matches[m.cursor], true
+}
+
+// HandleKey applies a keypress. It returns done once the user chose an item
+// or canceled; canceled distinguishes the two.
+func (m *Model) HandleKey(k tui.Key) (done, canceled bool) {
+	switch {
+	case k.Rune != 0:
+		m.query += string(k.Rune)
+		m.refilter()
+	case k.Name == tui.KeyBackspace:
+		if m.query != "" {
+			_, size := utf8.DecodeLastRuneInString(m.query)
+			m.query = m.query[:len(m.query)-size]
+			m.refilter()
+		}
+	case k.Name == tui.KeyClear:
+		m.query = ""
+		m.refilter()
+	case k.Name == tui.KeyUp:
+		if m.cursor > 0 {
+			m.cursor--
+		}
+	case k.Name == tui.KeyDown:
+		if m.cursor < len(m.matches)-1 {
+			m.cursor++
+		}
+	case k.Name == tui.KeyEnter:
+		if len(m.matches) > 0 {
+			return true, false
+		}
+	case k.Name == tui.KeyEscape:
+		return true, true
+	}
+	return false, false
+}
+
+func (m *Model) refilter() {
+	m.matches = Filter(m.items, m.query)
+	m.cursor = 0
+}
+
+func (m *Model) preview(it Item) []string {
+	if it.Preview == nil {
+		return nil
+	}
+	key := it.value()
+	if lines, ok := m.previews[key]; ok {
+		return lines
+	}
+	lines := it.Preview()
+	m.previews[key] = lines
+	return lines
+}
+
+// View renders the picker into at most height lines of width columns:
+// the prompt, the matching items and a preview of the highlighted one.
+func (m *Model) View(width, height int) []string {
+	if height < 3 {
+		height = 3
+	}
+	lines := []string{fmt.Sprintf("%s%s", m.Prompt, m.query)}
+
+	var preview []string
+	if sel, ok := m.Selected(); ok {
+		preview = m.preview(sel)
+	}
+	// Give the list at least half the space and the preview the rest
+	listRows := height - 1
+	if len(preview) > 0 {
+		listRows = max((height-1)/2, height-2-len(preview))
+	}
+	start := 0
+	if m.cursor >= listRows {
+		start = m.cursor - listRows + 1
+	}
+	for i := start; i < len(m.matches) && i < start+listRows; i++ {
+		it := m.matches[i]
+		marker := "  "
+		if it.Running {
+			marker = "● "
+		}
+		line := tui.Truncate(marker+it.Label, width-2)
+		if i == m.cursor {
+			lines = append(lines, "\x1b[7m> "+line+"\x1b[0m")
+		} else {
+			lines = append(lines, "  "+line)
+		}
+	}
+	if len(m.matches) == 0 {
+		lines = append(lines, "  (no matches)")
+	}
+	if len(preview) > 0 {
+		lines = append(lines, "\x1b[2m"+strings.Repeat("─", max(width, 1))+"\x1b[0m")
+		for _, l := range preview {
+			if len(lines) >= height {
+				break
+			}
+			lines = append(lines, tui.Truncate(l, width))
+		}
+	}
+	if len(lines) > height {
+		lines = lines[:height]
+	}
+	return lines
+}
+
+// Run shows the picker on the terminal behind in and out and returns the
+// chosen item's value. It fails if in is not a terminal.
+func Run(in *os.File, out io.Writer, prompt string, items []Item) (string, error) {
+	t, err := tui.Open(in, out)
+	if err != nil {
+		return "", err
+	}
+	defer t.Close()
+
+	m := NewModel(prompt, items)
+	for {
+		lines := m.View(t.Size())
+		// Leave the cursor at the end of the query line
+		t.Draw(lines, 0, tui.VisibleLen(lines[0]))
+		keys, err := t.ReadKeys(0)
+		if err != nil {
+			return "", err
+		}
+		for _, k := range keys {
+			done, canceled := m.HandleKey(k)
+			if canceled {
+				return "", ErrCanceled
+			}
+			if done {
+				sel, _ := m.Selected()
+				return sel.value(), nil
+			}
+		}
+	}
+}
+
+func (it Item) value() string {
+	if it.Value != "" {
+		return it.Value
+	}
+	return it.Label
+}
@@ -0,0 +1,85 @@
+package picker
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sbcinnovation/lmux/internal/tui"
+)
+
+func TestMatchRequiresOrderedSubsequence(t *testing.T) {
+	for _, tc := range []struct {
+		pattern, text string
+		ok            bool
+	}{
+		{"", "anything", true},
+		{"api", "my-api", true},
+		{"MAP", "my-api", true},
+		{"pia", "my-api", false},
+		{"apix", "api", false},
+	} {
+		if _, ok := Match(tc.pattern, tc.text); ok != tc.ok {
+			t.Errorf("Match(%q, %q) ok = %v, want %v", tc.pattern, tc.text, ok, tc.ok)
+		}
+	}
+}
+
+func TestFilterRanksConsecutiveAndWordStartMatchesFirst(t *testing.T) {
+	items := []Item{{Label: "a-pi-web"}, {Label: "backend-api"}, {Label: "api"}, {Label: "docs"}}
+	var got []string
+	for _, it := range Filter(items, "api") {
+		got = append(got, it.Label)
+	}
+	if want := "api backend-api a-pi-web"; strings.Join(got, " ") != want {
+		t.Fatalf("Filter = %q, want %q", strings.Join(got, " "), want)
+	}
+	if n := len(Filter(items, "")); n != len(items) {
+		t.Fatalf("empty pattern kept %d items, want %d", n, len(items))
+	}
+}
+
+func TestModelFiltersAndSelects(t *testing.T) {
+	m := NewModel("> ", []Item{{Label: "api", Value: "project:api"}, {Label: "web"}, {Label: "worker", Running: true}})
+	for _, k := range tui.DecodeKeys([]byte("w\x1b[B")) {
+		if done, _ := m.HandleKey(k); done {
+			t.Fatal("picker finished before enter")
+		}
+	}
+	if m.Query() != "w" || len(m.Matches()) != 2 {
+		t.Fatalf("query %q matched %d items, want 2", m.Query(), len(m.Matches()))
+	}
+	done, canceled := m.HandleKey(tui.Key{Name: tui.KeyEnter})
+	sel, _ := m.Selected()
+	if !done || canceled || sel.Label != "worker" {
+		t.Fatalf("enter selected %q (done %v, canceled %v), want worker", sel.Label, done, canceled)
+	}
+
+	view := strings.Join(m.View(40, 10), "\n")
+	if !strings.Contains(view, "● worker") {
+		t.Fatalf("view = %q, want running marker", view)
+	}
+
+	m.HandleKey(tui.Key{Name: tui.KeyBackspace})
+	if len(m.Matches()) != 3 {
+		t.Fatalf("backspace left %d matches, want 3", len(m.Matches()))
+	}
+	if sel, _ := m.Selected(); sel.value() != "project:api" {
+		t.Fatalf("selected value = %q, want project:api", sel.value())
+	}
+}
+
+func TestViewShowsPreviewOfHighlightedItem(t *testing.T) {
+	calls := 0
+	m := NewModel("> ", []Item{{Label: "api", Preview: func() []string {
+		calls++
+		return []string{"root: ~/api", "  1 server  go run ."}
+	}}})
+	m.View(40, 10)
+	view := strings.Join(m.View(40, 10), "\n")
+	if !strings.Contains(view, "1 server  go run .") {
+		t.Fatalf("view = %q, want preview", view)
+	}
+	if calls != 1 {
+		t.Fatalf("preview computed %d times, want 1", calls)
+	}
+}
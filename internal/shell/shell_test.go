@@ -0,0 +1,28 @@
+package shell
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestJoinQuotesOnlyWordsThatNeedIt(t *testing.T) {
+	got := Join([]string{"docker", "compose", "-f", "my compose.yml", "", "it's", "$HOME", "a;b"})
+	want := `docker compose -f 'my compose.yml' '' 'it'\''s' '$HOME' 'a;b'`
+	if got != want {
+		t.Fatalf("Join() = %s, want %s", got, want)
+	}
+}
+
+func TestSplitHonoursQuotes(t *testing.T) {
+	words, err := Split(`"/Applications/My Editor/bin/ed" --wait 'a b' c\ d "say \"hi\""`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"/Applications/My Editor/bin/ed", "--wait", "a b", "c d", `say "hi"`}
+	if strings.Join(words, "|") != strings.Join(want, "|") {
+		t.Fatalf("words = %q, want %q", words, want)
+	}
+	if _, err := Split(`nvim "unterminated`); err == nil {
+		t.Fatal("expected an error for an unterminated quote")
+	}
+}
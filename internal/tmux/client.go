@@ -0,0 +1,131 @@
+package tmux
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Client executes tmux commands. Args never include the tmux binary itself.
+type Client interface {
+	// Run executes a command, discarding its output.
+	Run(args ...string) error
+	// Output executes a command and returns its standard output.
+	Output(args ...string) (string, error)
+	// Attach executes a command connected to the current terminal, such as attach-session.
+	Attach(args ...string) error
+}
+
+// ExecClient is a Client that spawns a tmux process per command.
+type ExecClient struct {
+	Bin string
+	// Flags are global tmux flags, such as -f or -L, given before every command.
+	Flags []string
+}
+
+// NewExecClient returns a Client for the given tmux binary ("tmux" if empty),
+// failing if it cannot be found in PATH.
+func NewExecClient(bin string) (*ExecClient, error) {
+	if bin == "" {
+		bin = "tmux"
+	}
+	if _, err := exec.LookPath(bin); err != nil {
+		return nil, fmt.Errorf("tmux command %q not found in PATH", bin)
+	}
+	return &ExecClient{Bin: bin}, nil
+}
+
+// Run implements Client.
+func (c *ExecClient) Run(args ...string) error {
+	_, err := c.Output(args...)
+	return err
+}
+
+// Output implements Client. Errors include tmux's stderr.
+func (c *ExecClient) Output(args ...string) (string, error) {
+	cmd := c.command(args)
+	var out bytes.Buffer
+	var stderr bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if stderr.Len() > 0 {
+			return out.String(), fmt.Errorf("%v: %s", err, strings.TrimSpace(stderr.String()))
+		}
+		return out.String(), err
+	}
+	return out.String(), nil
+}
+
+// Attach implements Client.
+func (c *ExecClient) Attach(args ...string) error {
+	cmd := c.command(args)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// command returns the tmux process for args, after the client's flags.
+func (c *ExecClient) command(args []string) *exec.Cmd {
+	return exec.Command(c.Bin, append(slices.Clone(c.Flags), args...)...)
+}
+
+// Batcher is implemented by clients that can run a sequence of commands in a
+// single round-trip.
+type Batcher interface {
+	// RunBatch runs cmds in order and stops at the first failure, returning the
+	// index of the failed command with its error.
+	RunBatch(cmds [][]string) (int, error)
+}
+
+// batchMarker prefixes the progress lines RunBatch interleaves with commands.
+const batchMarker = "lmux-batch-done "
+
+// RunBatch implements Batcher by chaining commands with tmux's ";" separator.
+// tmux stops at the first failing command but does not say which one failed,
+// so a display-message marker is printed after each command and the last
+// marker seen identifies the failure. Markers need a session, so the batch
+// should start by creating or targeting one.
+func (c *ExecClient) RunBatch(cmds [][]string) (int, error) {
+	var args []string
+	for i, cmd := range cmds {
+		if i > 0 {
+			args = append(args, ";")
+		}
+		for _, a := range cmd {
+			args = append(args, escapeSeparator(a))
+		}
+		args = append(args, ";", "display-message", "-p", fmt.Sprintf("%s%d", batchMarker, i))
+	}
+	out, err := c.Output(args...)
+	if err == nil {
+		return -1, nil
+	}
+	failed := 0
+	for _, line := range strings.Split(out, "\n") {
+		if rest, ok := strings.CutPrefix(line, batchMarker); ok {
+			if n, convErr := strconv.Atoi(strings.TrimSpace(rest)); convErr == nil {
+				failed = n + 1
+			}
+		}
+	}
+	if failed >= len(cmds) {
+		// Every command ran; the marker itself failed
+		failed = len(cmds) - 1
+	}
+	return failed, err
+}
+
+// escapeSeparator keeps an argument ending in ";" from being read by tmux as
+// a command separator.
+func escapeSeparator(arg string) string {
+	if strings.HasSuffix(arg, ";") {
+		return arg[:len(arg)-1] + `\;`
+	}
+	return arg
+}
@@ -0,0 +1,403 @@
+package tmux
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/sbcinnovation/lmux/internal/shell"
+)
+
+// Notification is an asynchronous event sent by tmux in control mode, such as
+// %output or %window-add.
+type Notification struct {
+	// Name is the notification without its leading "%", e.g. "window-add".
+	Name string
+	// Args are the space-separated fields following the name. For "output"
+	// only the pane ID is kept here and the payload is in Data.
+	Args []string
+	// Data is the unescaped pane output of an "output" notification.
+	Data string
+}
+
+// ControlClient is a Client that keeps a single `tmux -C` control-mode
+// connection open and sends each command over it, avoiding a process spawn
+// per command. Commands are sent one at a time and each gets its own result.
+type ControlClient struct {
+	// Bin is the tmux binary, also used for Attach which needs a real terminal.
+	Bin string
+
+	proc   *exec.Cmd
+	stdin  io.WriteCloser
+	notify func(Notification)
+
+	mu      sync.Mutex // serializes commands
+	replies chan controlReply
+	done    chan struct{}
+	readErr error
+
+	pendingMu sync.Mutex
+	// initial counts the replies due before any command is sent, such as
+	// the dial command's.
+	initial int
+	// pending counts the replies callers wait for. Other blocks, such as
+	// those of commands run by hooks, are dropped.
+	pending int
+}
+
+type controlReply struct {
+	output string
+	failed bool
+}
+
+// errControlClosed is returned for commands sent after the connection ended.
+var errControlClosed = errors.New("tmux control connection closed")
+
+// DialControl starts `bin -C args...` and waits for tmux to acknowledge it.
+// Control clients must attach to a session, so args is typically
+// "attach-session -t NAME" or a "new-session" creating one. notify, if not
+// nil, receives notifications from the reading goroutine and must not call
+// back into the client.
+func DialControl(bin string, args []string, notify func(Notification)) (*ControlClient, error) {
+	if bin == "" {
+		bin = "tmux"
+	}
+	proc := exec.Command(bin, append([]string{"-C"}, args...)...)
+	stdin, err := proc.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := proc.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := proc.Start(); err != nil {
+		return nil, err
+	}
+	c := newControlClient(stdout, stdin, notify, 1)
+	c.Bin = bin
+	c.proc = proc
+
+	// The dial command produces the first reply
+	select {
+	case r := <-c.replies:
+		if r.failed {
+			c.Close()
+			return nil, fmt.Errorf("tmux control mode: %s", strings.TrimSpace(r.output))
+		}
+	case <-c.done:
+		c.Close()
+		return nil, fmt.Errorf("tmux control mode: %w", c.closedErr())
+	}
+	return c, nil
+}
+
+// newControlClient wires a client to an already running control-mode stream,
+// on which initial replies are due before any command is sent.
+func newControlClient(r io.Reader, w io.WriteCloser, notify func(Notification), initial int) *ControlClient {
+	c := &ControlClient{
+		stdin:   w,
+		notify:  notify,
+		replies: make(chan controlReply, 1),
+		done:    make(chan struct{}),
+		initial: initial,
+	}
+	go c.read(r)
+	return c
+}
+
+// Run implements Client.
+func (c *ControlClient) Run(args ...string) error {
+	_, err := c.Output(args...)
+	return err
+}
+
+// Output implements Client. tmux's error text is returned as the error.
+func (c *ControlClient) Output(args ...string) (string, error) {
+	line, err := controlLine(args)
+	if err != nil {
+		return "", err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pendingMu.Lock()
+	c.pending++
+	c.pendingMu.Unlock()
+	if _, err := io.WriteString(c.stdin, line+"\n"); err != nil {
+		return "", c.closedErr()
+	}
+	select {
+	case r := <-c.replies:
+		if r.failed {
+			return "", errors.New(strings.TrimSpace(r.output))
+		}
+		return r.output, nil
+	case <-c.done:
+		return "", c.closedErr()
+	}
+}
+
+// Attach implements Client by spawning a regular client, since a control
+// connection cannot drive the terminal.
+func (c *ControlClient) Attach(args ...string) error {
+	return (&ExecClient{Bin: c.Bin}).Attach(args...)
+}
+
+// RunBatch implements Batcher. Commands share the open connection and run
+// until the first one that fails.
+func (c *ControlClient) RunBatch(cmds [][]string) (int, error) {
+	for i, cmd := range cmds {
+		if err := c.Run(cmd...); err != nil {
+			return i, err
+		}
+	}
+	return -1, nil
+}
+
+// Close ends the control connection and waits for tmux to exit. tmux's exit
+// status is ignored since closing the connection is how the client detaches.
+func (c *ControlClient) Close() error {
+	err := c.stdin.Close()
+	if c.proc != nil {
+		<-c.done
+		_ = c.proc.Wait()
+	}
+	return err
+}
+
+// ControlBackend is a Client for building sessions over a control-mode
+// connection. Commands run as processes until a new-session succeeds; the
+// rest then go over one ControlClient attached to that session. Commands
+// acting on the user's own client, such as switch-client, always run as
+// processes, since over the connection they would act on the control client.
+type ControlBackend struct {
+	exec   *ExecClient
+	notify func(Notification)
+
+	mu   sync.Mutex
+	conn *ControlClient
+}
+
+// userClientCommands are run as processes by ControlBackend.
+var userClientCommands = map[string]bool{
+	"switch-client":   true,
+	"detach-client":   true,
+	"display-message": true,
+	"display-popup":   true,
+}
+
+// NewControlBackend returns a ControlBackend that runs commands as processes
+// with client and dials with its binary and flags. notify, if not nil,
+// receives the connection's notifications.
+func NewControlBackend(client *ExecClient, notify func(Notification)) *ControlBackend {
+	var processes ExecClient
+	if client != nil {
+		processes = *client
+	}
+	if processes.Bin == "" {
+		processes.Bin = "tmux"
+	}
+	return &ControlBackend{exec: &processes, notify: notify}
+}
+
+// Connected reports whether commands go over a control connection.
+func (b *ControlBackend) Connected() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.conn != nil
+}
+
+// Run implements Client.
+func (b *ControlBackend) Run(args ...string) error {
+	_, err := b.Output(args...)
+	return err
+}
+
+// Output implements Client. If the connection cannot be opened after a
+// new-session, commands keep running as processes.
+func (b *ControlBackend) Output(args ...string) (string, error) {
+	b.mu.Lock()
+	conn := b.conn
+	b.mu.Unlock()
+	if conn != nil && len(args) > 0 && !userClientCommands[args[0]] {
+		return conn.Output(args...)
+	}
+	out, err := b.exec.Output(args...)
+	if err == nil && len(args) > 0 && args[0] == "new-session" {
+		b.dial(flagValue(args, "-s"))
+	}
+	return out, err
+}
+
+// dial opens the control connection to session unless one is open. The lock
+// is held throughout so concurrent callers share one connection.
+func (b *ControlBackend) dial(session string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.conn != nil || session == "" {
+		return
+	}
+	if conn, err := DialControl(b.exec.Bin, append(slices.Clone(b.exec.Flags), "attach-session", "-t", "="+session), b.notify); err == nil {
+		b.conn = conn
+	}
+}
+
+// RunBatch implements Batcher, sending the commands one by one so each
+// failure is reported by the command that caused it.
+func (b *ControlBackend) RunBatch(cmds [][]string) (int, error) {
+	for i, cmd := range cmds {
+		if err := b.Run(cmd...); err != nil {
+			return i, err
+		}
+	}
+	return -1, nil
+}
+
+// Attach implements Client, closing the connection first so the control
+// client does not stay attached beside the user's.
+func (b *ControlBackend) Attach(args ...string) error {
+	if err := b.Close(); err != nil {
+		return err
+	}
+	return b.exec.Attach(args...)
+}
+
+// Close ends the control connection, if one is open. Later commands run as
+// processes.
+func (b *ControlBackend) Close() error {
+	b.mu.Lock()
+	conn := b.conn
+	b.conn = nil
+	b.mu.Unlock()
+	if conn == nil {
+		return nil
+	}
+	return conn.Close()
+}
+
+func (c *ControlClient) closedErr() error {
+	<-c.done
+	if c.readErr != nil {
+		return fmt.Errorf("%w: %v", errControlClosed, c.readErr)
+	}
+	return errControlClosed
+}
+
+// read parses the control-mode stream. Command output arrives between
+// %begin and a matching %end or %error line; other lines starting with "%"
+// are notifications.
+func (c *ControlClient) read(r io.Reader) {
+	defer close(c.done)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var block []string
+	var blockID string
+	inBlock := false
+	for scanner.Scan() {
+		line := scanner.Text()
+		if inBlock {
+			fields := strings.Fields(line)
+			if len(fields) >= 3 && (fields[0] == "%end" || fields[0] == "%error") && fields[2] == blockID {
+				inBlock = false
+				output := ""
+				if len(block) > 0 {
+					output = strings.Join(block, "\n") + "\n"
+				}
+				// The flags field is 1 for commands this client sent
+				fromClient := len(fields) < 4 || fields[3] != "0"
+				c.reply(controlReply{output: output, failed: fields[0] == "%error"}, fromClient)
+				continue
+			}
+			block = append(block, line)
+			continue
+		}
+		if strings.HasPrefix(line, "%begin ") {
+			fields := strings.Fields(line)
+			if len(fields) >= 3 {
+				inBlock = true
+				blockID = fields[2]
+				block = block[:0]
+			}
+			continue
+		}
+		if strings.HasPrefix(line, "%") && c.notify != nil {
+			c.notify(parseNotification(line))
+		}
+	}
+	c.readErr = scanner.Err()
+}
+
+// reply hands a block to the caller waiting for it, if any. Commands are
+// sent one at a time, so the channel has room whenever a caller is pending.
+func (c *ControlClient) reply(r controlReply, fromClient bool) {
+	c.pendingMu.Lock()
+	expected := false
+	switch {
+	case c.initial > 0:
+		c.initial--
+		expected = true
+	case fromClient && c.pending > 0:
+		c.pending--
+		expected = true
+	}
+	c.pendingMu.Unlock()
+	if expected {
+		c.replies <- r
+	}
+}
+
+// parseNotification splits a notification line into its name and fields.
+func parseNotification(line string) Notification {
+	name, rest, _ := strings.Cut(strings.TrimPrefix(line, "%"), " ")
+	n := Notification{Name: name}
+	if name == "output" {
+		pane, data, _ := strings.Cut(rest, " ")
+		n.Args = []string{pane}
+		n.Data = unescapeOutput(data)
+		return n
+	}
+	n.Args = strings.Fields(rest)
+	return n
+}
+
+// unescapeOutput decodes the octal escapes (\ooo) tmux uses in %output.
+func unescapeOutput(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+4 <= len(s) {
+			if v, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// controlLine renders args as one line of tmux command syntax. Words are
+// quoted as for a shell, so tmux applies no expansion or separator handling
+// to them.
+func controlLine(args []string) (string, error) {
+	if len(args) == 0 {
+		return "", errors.New("empty tmux command")
+	}
+	for _, a := range args {
+		if strings.ContainsAny(a, "\r\n") {
+			return "", fmt.Errorf("tmux control mode cannot send arguments containing newlines: %q", a)
+		}
+	}
+	return shell.Join(args), nil
+}
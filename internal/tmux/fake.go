@@ -0,0 +1,143 @@
+package tmux
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Fake is an in-memory Client that records every command it receives instead
+// of running tmux. It tracks sessions created and killed through it so that
+// has-session and list-sessions behave consistently.
+type Fake struct {
+	mu sync.Mutex
+
+	// Log holds the arguments of every command received, in order.
+	Log [][]string
+	// Sessions are the session names the fake reports as running.
+	Sessions []string
+	// Outputs maps a tmux subcommand (e.g. "show") to the output it returns.
+	Outputs map[string]string
+	// Errors maps a tmux subcommand to the error it returns.
+	Errors map[string]error
+}
+
+// NewFake returns a Fake reporting the given sessions as running.
+func NewFake(sessions ...string) *Fake {
+	return &Fake{Sessions: sessions}
+}
+
+// Commands returns the recorded commands, each joined with spaces.
+func (f *Fake) Commands() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	cmds := make([]string, len(f.Log))
+	for i, args := range f.Log {
+		cmds[i] = strings.Join(args, " ")
+	}
+	return cmds
+}
+
+// Run implements Client.
+func (f *Fake) Run(args ...string) error {
+	_, err := f.Output(args...)
+	return err
+}
+
+// Attach implements Client.
+func (f *Fake) Attach(args ...string) error {
+	return f.Run(args...)
+}
+
+// Output implements Client.
+func (f *Fake) Output(args ...string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Log = append(f.Log, append([]string(nil), args...))
+	if len(args) == 0 {
+		return "", nil
+	}
+	sub := args[0]
+	if err, ok := f.Errors[sub]; ok && err != nil {
+		return "", err
+	}
+	if out, ok := f.Outputs[sub]; ok {
+		return out, nil
+	}
+
+	switch sub {
+	case "has-session":
+		if f.find(flagValue(args, "-t")) < 0 {
+			return "", fmt.Errorf("can't find session: %s", flagValue(args, "-t"))
+		}
+	case "list-sessions":
+		if len(f.Sessions) == 0 {
+			return "", nil
+		}
+		return strings.Join(f.Sessions, "\n") + "\n", nil
+	case "new-session":
+		if name := flagValue(args, "-s"); name != "" {
+			if f.indexOf(name) >= 0 {
+				return "", fmt.Errorf("duplicate session: %s", name)
+			}
+			f.Sessions = append(f.Sessions, name)
+		}
+	case "kill-session":
+		i := f.find(flagValue(args, "-t"))
+		if i < 0 {
+			return "", fmt.Errorf("can't find session: %s", flagValue(args, "-t"))
+		}
+		f.Sessions = append(f.Sessions[:i], f.Sessions[i+1:]...)
+	case "kill-server":
+		f.Sessions = nil
+	}
+	return "", nil
+}
+
+// find resolves a target to a session index like tmux does: an exact name,
+// or without the "=" prefix, failing that, the only session it prefixes.
+func (f *Fake) find(target string) int {
+	name := sessionName(target)
+	if i := f.indexOf(name); i >= 0 || strings.HasPrefix(target, "=") {
+		return i
+	}
+	match := -1
+	for i, s := range f.Sessions {
+		if strings.HasPrefix(s, name) {
+			if match >= 0 {
+				return -1
+			}
+			match = i
+		}
+	}
+	return match
+}
+
+func (f *Fake) indexOf(session string) int {
+	for i, s := range f.Sessions {
+		if s == session {
+			return i
+		}
+	}
+	return -1
+}
+
+// flagValue returns the value following flag in args, or "" if absent.
+func flagValue(args []string, flag string) string {
+	for i := 0; i < len(args)-1; i++ {
+		if args[i] == flag {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+// sessionName strips any window or pane part, and the exact-match "=" prefix,
+// from a tmux target.
+func sessionName(target string) string {
+	target = strings.TrimPrefix(target, "=")
+	if i := strings.IndexAny(target, ":."); i >= 0 {
+		return target[:i]
+	}
+	return target
+}
@@ -0,0 +1,208 @@
+package tmux
+
+import (
+	"fmt"
+	"strings"
+
+	cfg "github.com/sbcinnovation/lmux/internal/config"
+)
+
+// Command is a single tmux invocation used to build a session.
+type Command struct {
+	// Args are the tmux arguments, without the tmux binary itself.
+	Args []string
+	// Desc describes what the command builds and prefixes errors it causes.
+	Desc string
+	// Optional commands are best effort; their failure does not abort the build.
+	Optional bool
+
+	// Wait makes this step a readiness gate instead of a tmux command: later
+	// commands are held back until its conditions hold.
+	Wait *cfg.WaitFor
+	// Target is the pane whose output a Wait matches.
+	Target string
+	// Dir resolves a Wait's relative file and runs its command.
+	Dir string
+}
+
+// Plan returns the tmux commands StartProject runs to build a new session for
+// the project, in order. It does not touch tmux, so it is safe for dry runs.
+// When windows declare depends_on, all windows are created first and then set
+// up in dependency order; StartProject runs independent windows concurrently.
+func Plan(project cfg.Project) []Command {
+	if project.HasDependencies() {
+		st := planStages(project)
+		order, err := project.WindowOrder()
+		if err != nil {
+			// LoadProject rejects bad dependencies; fall back to the configured order
+			order = order[:0]
+			for i := range project.Windows {
+				order = append(order, i)
+			}
+		}
+		cmds := st.create
+		for _, i := range order {
+			cmds = append(cmds, st.setup[i]...)
+		}
+		return append(cmds, st.finish...)
+	}
+
+	cmds := []Command{newSession(project)}
+	for i, w := range project.Windows {
+		if i > 0 {
+			cmds = append(cmds, newWindow(project, w))
+		}
+		cmds = append(cmds, windowCommands(project, i, w)...)
+	}
+	return append(cmds, startupCommands(project)...)
+}
+
+// stages is a plan split for dependency-ordered builds.
+type stages struct {
+	create []Command   // session and every window, in configured order
+	setup  [][]Command // each window's layout, commands and waits, by index
+	finish []Command   // startup window and pane selection
+}
+
+func planStages(project cfg.Project) stages {
+	st := stages{
+		create: []Command{newSession(project)},
+		setup:  make([][]Command, len(project.Windows)),
+		finish: startupCommands(project),
+	}
+	for i, w := range project.Windows {
+		if i > 0 {
+			st.create = append(st.create, newWindow(project, w))
+		}
+		st.setup[i] = windowCommands(project, i, w)
+	}
+	return st
+}
+
+// newSession creates the detached session along with its first window.
+func newSession(project cfg.Project) Command {
+	args := []string{"new-session", "-d", "-s", project.Name}
+	if len(project.Windows) > 0 && project.Windows[0].Name != "" {
+		args = append(args, "-n", project.Windows[0].Name)
+	}
+	// Use first window's root if provided, otherwise project root
+	if len(project.Windows) > 0 {
+		if root := windowRoot(project, project.Windows[0]); root != "" {
+			args = append(args, "-c", root)
+		}
+	}
+	return Command{Args: args, Desc: "failed creating session"}
+}
+
+// newWindow appends a window to the session.
+func newWindow(project cfg.Project, w cfg.Window) Command {
+	// The trailing colon keeps tmux from matching the session name against window names
+	args := []string{"new-window", "-t", project.Name + ":"}
+	if w.Name != "" {
+		args = append(args, "-n", w.Name)
+	}
+	if root := windowRoot(project, w); root != "" {
+		args = append(args, "-c", root)
+	}
+	return Command{Args: args, Desc: fmt.Sprintf("failed creating window %s", w.Name)}
+}
+
+// startupCommands select the startup window and pane, if configured.
+func startupCommands(project cfg.Project) []Command {
+	if project.StartupWindow == "" {
+		return nil
+	}
+	cmds := []Command{{
+		Args: []string{"select-window", "-t", fmt.Sprintf("%s:%s", project.Name, project.StartupWindow)},
+		Desc: fmt.Sprintf("failed selecting startup window %s", project.StartupWindow),
+	}}
+	if project.StartupPane > 0 {
+		cmds = append(cmds, Command{
+			Args: []string{"select-pane", "-t", fmt.Sprintf("%s:%s.%d", project.Name, project.StartupWindow, project.StartupPane)},
+			Desc: fmt.Sprintf("failed selecting startup pane %d", project.StartupPane),
+		})
+	}
+	return cmds
+}
+
+// windowCommands lays out a freshly created window, types its commands and
+// gates what follows on the window's and panes' wait_for conditions.
+func windowCommands(project cfg.Project, index int, w cfg.Window) []Command {
+	target := windowTarget(project.Name, index, w)
+	root := windowRoot(project, w)
+	desc := fmt.Sprintf("window %s", windowLabel(index, w))
+	var cmds []Command
+
+	// Layout not fully supported; best effort
+	if w.Layout != "" {
+		cmds = append(cmds, Command{Args: []string{"select-layout", "-t", target, w.Layout}, Optional: true})
+	}
+
+	// If panes specified, split and run commands per pane; otherwise run window commands.
+	// A split makes the new pane active, so commands sent to the window target land in
+	// the pane just created regardless of pane-base-index.
+	if len(w.Panes) > 0 {
+		for paneIndex, pane := range w.Panes {
+			if paneIndex > 0 {
+				cmds = append(cmds, Command{
+					Args: []string{"split-window", "-t", target, "-h"},
+					Desc: fmt.Sprintf("%s: failed splitting pane %d", desc, paneIndex),
+				})
+				// Keep panes spread out so further splits have room
+				cmds = append(cmds, Command{Args: []string{"select-layout", "-t", target, "tiled"}, Optional: true})
+			}
+			paneDesc := fmt.Sprintf("%s: pane %d", desc, paneIndex)
+			for _, c := range pane.Commands {
+				cmds = append(cmds, sendKeys(target, c, paneDesc))
+			}
+			if pane.WaitFor != nil {
+				cmds = append(cmds, Command{Wait: pane.WaitFor, Target: target, Dir: root, Desc: paneDesc})
+			}
+		}
+	} else {
+		for _, c := range w.Commands {
+			cmds = append(cmds, sendKeys(target, c, desc))
+		}
+	}
+	if w.WaitFor != nil {
+		cmds = append(cmds, Command{Wait: w.WaitFor, Target: target, Dir: root, Desc: desc})
+	}
+	return cmds
+}
+
+// sendKeys types a command into a pane and submits it.
+// Use Enter instead of C-m: on Windows psmux, C-m sends Ctrl+M (\r) as literal
+// input (^M) rather than the Enter key.
+func sendKeys(target, cmd, desc string) Command {
+	cmd = strings.ReplaceAll(cmd, "\r", "")
+	return Command{Args: []string{"send-keys", "-t", target, cmd, "Enter"}, Desc: desc}
+}
+
+// windowRoot returns the expanded start directory for a window, defaulting to the project root.
+func windowRoot(project cfg.Project, w cfg.Window) string {
+	root := w.Root
+	if strings.TrimSpace(root) == "" {
+		root = project.Root
+	}
+	if strings.TrimSpace(root) == "" {
+		return ""
+	}
+	return cfg.ExpandPath(root)
+}
+
+// windowLabel names a window in error messages.
+func windowLabel(index int, w cfg.Window) string {
+	if strings.TrimSpace(w.Name) != "" {
+		return w.Name
+	}
+	return fmt.Sprintf("#%d", index)
+}
+
+// windowTarget returns a tmux target for the window, preferring name to avoid base-index issues.
+func windowTarget(session string, index int, w cfg.Window) string {
+	if strings.TrimSpace(w.Name) != "" {
+		return fmt.Sprintf("%s:%s", session, w.Name)
+	}
+	// Fallback to numeric index (may fail if base-index != 0)
+	return fmt.Sprintf("%s:%d", session, index)
+}
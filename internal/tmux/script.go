@@ -0,0 +1,325 @@
+package tmux
+
+import (
+	"fmt"
+	"regexp/syntax"
+	"strings"
+	"unicode"
+
+	cfg "github.com/sbcinnovation/lmux/internal/config"
+	"github.com/sbcinnovation/lmux/internal/shell"
+)
+
+// FormatCommand renders a planned command as a shell command line, with
+// flags given to tmux before the command. Wait steps are rendered as
+// comments.
+func FormatCommand(tmuxCmd string, flags []string, c Command) string {
+	if c.Wait != nil {
+		return fmt.Sprintf("# %s: wait for %s (timeout %s)", c.Desc, c.Wait, c.Wait.Timeout)
+	}
+	if tmuxCmd == "" {
+		tmuxCmd = "tmux"
+	}
+	return shell.Join(append(append([]string{tmuxCmd}, flags...), c.Args...))
+}
+
+// Script returns a standalone POSIX shell script that builds the project's
+// session with plain tmux calls and then attaches to it, so it can run on
+// machines without lmux installed. It fails if a wait_for output pattern has
+// no POSIX equivalent for grep.
+func Script(project cfg.Project) (string, error) {
+	tmuxCmd := project.TmuxCommand
+	if tmuxCmd == "" {
+		tmuxCmd = "tmux"
+	}
+	session := shell.Quote(project.Name)
+	tmux := `"$TMUX_BIN"`
+	if len(project.TmuxFlags) > 0 {
+		tmux += " " + shell.Join(project.TmuxFlags)
+	}
+
+	var b strings.Builder
+	b.WriteString("#!/bin/sh\n")
+	fmt.Fprintf(&b, "# Session %q exported by lmux.\n", project.Name)
+	b.WriteString("set -e\n\n")
+	fmt.Fprintf(&b, "TMUX_BIN=${TMUX_BIN:-%s}\n\n", shell.Quote(tmuxCmd))
+	plan := Plan(project)
+	waits, ports := false, false
+	for _, c := range plan {
+		if c.Wait != nil {
+			waits = true
+			ports = ports || c.Wait.Port != 0
+		}
+	}
+	if ports {
+		b.WriteString(ncCheck)
+	}
+	if waits {
+		b.WriteString(waitFunc)
+	}
+	fmt.Fprintf(&b, "if ! %s has-session -t %s 2>/dev/null; then\n", tmux, session)
+	for _, c := range plan {
+		if c.Wait != nil {
+			cond, err := waitCondition(tmux, c)
+			if err != nil {
+				return "", fmt.Errorf("%s: %w", c.Desc, err)
+			}
+			fmt.Fprintf(&b, "  %s\n", FormatCommand("", nil, c))
+			fmt.Fprintf(&b, "  lmux_wait %d %s\n", int(c.Wait.Timeout.Seconds()), shell.Quote(cond))
+			continue
+		}
+		line := tmux + " " + shell.Join(c.Args)
+		if c.Optional {
+			line += " || true"
+		}
+		fmt.Fprintf(&b, "  %s\n", line)
+	}
+	b.WriteString("fi\n\n")
+	b.WriteString("if [ -n \"$TMUX\" ]; then\n")
+	fmt.Fprintf(&b, "  exec %s switch-client -t %s\n", tmux, session)
+	b.WriteString("fi\n")
+	fmt.Fprintf(&b, "exec %s attach-session -t %s\n", tmux, session)
+	return b.String(), nil
+}
+
+// ncCheck stops the script up front when port waits cannot be checked,
+// rather than letting them time out.
+const ncCheck = `if ! command -v nc >/dev/null 2>&1; then
+  echo "nc is needed to wait for ports; install netcat" >&2
+  exit 1
+fi
+
+`
+
+// waitFunc polls a shell condition once a second until it holds or the
+// timeout in seconds expires.
+const waitFunc = `lmux_wait() {
+  timeout=$1
+  start=$(date +%s)
+  until eval "$2" >/dev/null 2>&1; do
+    if [ $(($(date +%s) - start)) -ge "$timeout" ]; then
+      echo "timed out after ${timeout}s waiting for: $2" >&2
+      exit 1
+    fi
+    sleep 1
+  done
+}
+
+`
+
+// waitCondition renders a wait step as a shell test for lmux_wait, reading
+// pane output with the tmux command line tmux.
+func waitCondition(tmux string, c Command) (string, error) {
+	w := c.Wait
+	var conds []string
+	if w.Port != 0 {
+		conds = append(conds, fmt.Sprintf("nc -z %s %d", shell.Quote(w.Host), w.Port))
+	}
+	if w.File != "" {
+		conds = append(conds, fmt.Sprintf("[ -e %s ]", shell.Quote(waitPath(c))))
+	}
+	if w.Command != "" {
+		cmd := "sh -c " + shell.Quote(w.Command)
+		if c.Dir != "" {
+			cmd = fmt.Sprintf("(cd %s && %s)", shell.Quote(c.Dir), cmd)
+		}
+		conds = append(conds, cmd)
+	}
+	if w.Output != nil {
+		pattern, err := extendedRegexp(w.Output.String())
+		if err != nil {
+			return "", err
+		}
+		conds = append(conds, fmt.Sprintf(`%s capture-pane -p -t %s | grep -Eq %s`, tmux, shell.Quote(c.Target), shell.Quote(pattern)))
+	}
+	return strings.Join(conds, " && "), nil
+}
+
+// extendedRegexp translates a Go regexp into a POSIX extended regexp for
+// grep -E, which matches a line at a time like wait_for output does. Only
+// whether a line matches counts, so lazy repetition becomes greedy.
+// Constructs grep has no equivalent for are an error.
+func extendedRegexp(pattern string) (string, error) {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := writeERE(&b, re.Simplify()); err != nil {
+		return "", fmt.Errorf("output pattern /%s/ cannot be exported: %w", strings.TrimPrefix(pattern, "(?m)"), err)
+	}
+	return b.String(), nil
+}
+
+func writeERE(b *strings.Builder, re *syntax.Regexp) error {
+	switch re.Op {
+	case syntax.OpEmptyMatch:
+		return nil
+	case syntax.OpLiteral:
+		for _, r := range re.Rune {
+			if r == '\n' {
+				return fmt.Errorf("it matches across lines")
+			}
+			if re.Flags&syntax.FoldCase != 0 && unicode.ToLower(r) != unicode.ToUpper(r) {
+				fmt.Fprintf(b, "[%c%c]", unicode.ToUpper(r), unicode.ToLower(r))
+				continue
+			}
+			if strings.ContainsRune(`.[]()*+?{}|^$\`, r) {
+				b.WriteByte('\\')
+			}
+			b.WriteRune(r)
+		}
+	case syntax.OpCharClass:
+		return writeBracket(b, re.Rune)
+	case syntax.OpAnyCharNotNL, syntax.OpAnyChar:
+		b.WriteByte('.')
+	case syntax.OpBeginLine:
+		b.WriteByte('^')
+	case syntax.OpEndLine:
+		b.WriteByte('$')
+	case syntax.OpCapture:
+		b.WriteByte('(')
+		if err := writeERE(b, re.Sub[0]); err != nil {
+			return err
+		}
+		b.WriteByte(')')
+	case syntax.OpStar, syntax.OpPlus, syntax.OpQuest, syntax.OpRepeat:
+		if err := writeAtom(b, re.Sub[0]); err != nil {
+			return err
+		}
+		switch {
+		case re.Op == syntax.OpStar:
+			b.WriteByte('*')
+		case re.Op == syntax.OpPlus:
+			b.WriteByte('+')
+		case re.Op == syntax.OpQuest:
+			b.WriteByte('?')
+		case re.Max == re.Min:
+			fmt.Fprintf(b, "{%d}", re.Min)
+		case re.Max < 0:
+			fmt.Fprintf(b, "{%d,}", re.Min)
+		default:
+			fmt.Fprintf(b, "{%d,%d}", re.Min, re.Max)
+		}
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			if sub.Op == syntax.OpAlternate {
+				if err := writeAtom(b, sub); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := writeERE(b, sub); err != nil {
+				return err
+			}
+		}
+	case syntax.OpAlternate:
+		for i, sub := range re.Sub {
+			if sub.Op == syntax.OpEmptyMatch {
+				return fmt.Errorf("it has an empty alternative")
+			}
+			if i > 0 {
+				b.WriteByte('|')
+			}
+			if err := writeERE(b, sub); err != nil {
+				return err
+			}
+		}
+	case syntax.OpBeginText, syntax.OpEndText:
+		return fmt.Errorf("grep has no start or end of text, use ^ and $")
+	case syntax.OpWordBoundary, syntax.OpNoWordBoundary:
+		return fmt.Errorf("grep -E has no word boundaries")
+	default:
+		return fmt.Errorf("grep -E has no equivalent of %s", re)
+	}
+	return nil
+}
+
+// writeAtom writes re, grouped unless it is a single character or group.
+func writeAtom(b *strings.Builder, re *syntax.Regexp) error {
+	switch {
+	case re.Op == syntax.OpLiteral && len(re.Rune) == 1 && re.Flags&syntax.FoldCase == 0,
+		re.Op == syntax.OpCharClass, re.Op == syntax.OpAnyChar, re.Op == syntax.OpAnyCharNotNL,
+		re.Op == syntax.OpCapture:
+		return writeERE(b, re)
+	}
+	b.WriteByte('(')
+	if err := writeERE(b, re); err != nil {
+		return err
+	}
+	b.WriteByte(')')
+	return nil
+}
+
+// writeBracket writes the ranges of a character class as a bracket
+// expression, negated when the class runs to the last rune. Newlines are
+// left out since grep never sees them inside a line.
+func writeBracket(b *strings.Builder, ranges []rune) error {
+	negate := len(ranges) > 0 && ranges[len(ranges)-1] == unicode.MaxRune
+	if negate {
+		// Complement the sorted ranges
+		var inv []rune
+		next := rune(0)
+		for i := 0; i < len(ranges); i += 2 {
+			if ranges[i] > next {
+				inv = append(inv, next, ranges[i]-1)
+			}
+			next = ranges[i+1] + 1
+		}
+		ranges = inv
+	}
+	var items string
+	var closing, dash, caret bool
+	for i := 0; i < len(ranges); i += 2 {
+		lo, hi := ranges[i], ranges[i+1]
+		// Split the range around characters that are special in brackets
+		for _, special := range []rune{'\n', '-', ']', '^'} {
+			if special < lo || special > hi {
+				continue
+			}
+			if lo < special {
+				items += bracketRange(lo, special-1)
+			}
+			closing = closing || special == ']'
+			dash = dash || special == '-'
+			caret = caret || special == '^'
+			lo = special + 1
+		}
+		if lo <= hi {
+			items += bracketRange(lo, hi)
+		}
+	}
+	// ] must come first, ^ anywhere but first and - last
+	if closing {
+		items = "]" + items
+	}
+	if caret {
+		items += "^"
+	}
+	if dash {
+		items += "-"
+	}
+	switch {
+	case items == "" && !negate:
+		return fmt.Errorf("it matches across lines")
+	case items == "":
+		b.WriteByte('.')
+	case items == "^" && !negate:
+		b.WriteString(`\^`)
+	case negate:
+		b.WriteString("[^" + items + "]")
+	default:
+		b.WriteString("[" + items + "]")
+	}
+	return nil
+}
+
+func bracketRange(lo, hi rune) string {
+	switch {
+	case lo == hi:
+		return string(lo)
+	case lo+1 == hi:
+		return string(lo) + string(hi)
+	}
+	return string(lo) + "-" + string(hi)
+}
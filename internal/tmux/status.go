@@ -0,0 +1,101 @@
+package tmux
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Session describes a running tmux session.
+type Session struct {
+	Name     string
+	Attached int // number of attached clients
+	Windows  int
+	Created  time.Time
+}
+
+// Pane describes a pane of a running session.
+type Pane struct {
+	WindowIndex int
+	WindowName  string
+	Index       int
+	Command     string // pane_current_command, e.g. "nvim"
+}
+
+// Sessions returns details of every running tmux session.
+func Sessions(c Client) ([]Session, error) {
+	// tmux replaces tabs in formats, so fields are space separated with the
+	// free-form name last
+	out, err := c.Output("list-sessions", "-F", "#{session_attached} #{session_windows} #{session_created} #{session_name}")
+	if err != nil {
+		if strings.Contains(err.Error(), "no server running") {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("list tmux sessions: %w", err)
+	}
+	var sessions []Session
+	for _, line := range splitLines(out) {
+		fields := strings.SplitN(line, " ", 4)
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("unexpected list-sessions output: %q", line)
+		}
+		s := Session{Name: fields[3]}
+		s.Attached, _ = strconv.Atoi(fields[0])
+		s.Windows, _ = strconv.Atoi(fields[1])
+		if created, err := strconv.ParseInt(fields[2], 10, 64); err == nil {
+			s.Created = time.Unix(created, 0)
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, nil
+}
+
+// Panes returns every pane of the named session, in window and pane order.
+func Panes(c Client, session string) ([]Pane, error) {
+	// "=" makes tmux match the session name exactly rather than as a prefix
+	out, err := c.Output("list-panes", "-s", "-t", "="+session, "-F", "#{window_index} #{pane_index} #{pane_current_command} #{window_name}")
+	if err != nil {
+		return nil, fmt.Errorf("list panes of %s: %w", session, err)
+	}
+	var panes []Pane
+	for _, line := range splitLines(out) {
+		fields := strings.SplitN(line, " ", 4)
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("unexpected list-panes output: %q", line)
+		}
+		p := Pane{Command: fields[2], WindowName: fields[3]}
+		p.WindowIndex, _ = strconv.Atoi(fields[0])
+		p.Index, _ = strconv.Atoi(fields[1])
+		panes = append(panes, p)
+	}
+	return panes, nil
+}
+
+// CapturePane returns up to the last n lines of output in the target pane,
+// reaching into its scrollback and ignoring blank rows below the cursor.
+func CapturePane(c Client, target string, n int) ([]string, error) {
+	out, err := c.Output("capture-pane", "-p", "-J", "-t", target, "-S", strconv.Itoa(-n))
+	if err != nil {
+		return nil, fmt.Errorf("capture pane %s: %w", target, err)
+	}
+	lines := strings.Split(strings.TrimRight(out, "\n"), "\n")
+	// The visible screen is padded with blank lines below the cursor
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines, nil
+}
+
+func splitLines(out string) []string {
+	var lines []string
+	for _, line := range strings.Split(out, "\n") {
+		if line = strings.TrimRight(line, "\r"); strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
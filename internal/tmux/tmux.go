@@ -1,13 +1,12 @@
 package tmux
 
 import (
-	"bytes"
 	"errors"
 	"fmt"
 	"os"
 	"os/exec"
-	"strconv"
 	"strings"
+	"sync"
 
 	cfg "github.com/sbcinnovation/lmux/internal/config"
 )
@@ -30,48 +29,63 @@ func TmuxVersion() (string, error) {
 }
 
 // DetachClient detaches the current tmux client.
-func DetachClient() error {
+func DetachClient(c Client) error {
 	if os.Getenv("TMUX") == "" {
 		return errors.New("not inside a tmux client")
 	}
-	if err := CheckTmuxInstalled(); err != nil {
-		return err
+	return c.Run("detach-client")
+}
+
+// CurrentClient returns the name of the tmux client lmux runs under, such as
+// "/dev/pts/3".
+func CurrentClient(c Client) (string, error) {
+	if os.Getenv("TMUX") == "" {
+		return "", errors.New("not inside a tmux client")
+	}
+	out, err := c.Output("display-message", "-p", "#{client_name}")
+	if err != nil {
+		return "", err
 	}
-	return run("tmux", "detach-client")
+	return strings.TrimSpace(out), nil
 }
 
-// KillSession stops the named tmux session.
-func KillSession(session string) error {
-	if err := CheckTmuxInstalled(); err != nil {
-		return err
+// SwitchClient moves client, or the current client if empty, to session.
+func SwitchClient(c Client, client, session string) error {
+	args := []string{"switch-client"}
+	if client != "" {
+		args = append(args, "-c", client)
 	}
-	return run("tmux", "kill-session", "-t", session)
+	// "=" makes tmux match the session name exactly rather than as a prefix
+	return c.Run(append(args, "-t", "="+session)...)
+}
+
+// KillSession stops the named tmux session.
+func KillSession(c Client, session string) error {
+	return c.Run("kill-session", "-t", "="+session)
+}
+
+// RenameSession renames a running tmux session.
+func RenameSession(c Client, session, name string) error {
+	return c.Run("rename-session", "-t", "="+session, name)
 }
 
 // KillServer stops the tmux server and all sessions.
-func KillServer() error {
-	if err := CheckTmuxInstalled(); err != nil {
-		return err
-	}
-	return run("tmux", "kill-server")
+func KillServer(c Client) error {
+	return c.Run("kill-server")
 }
 
 // ListSessions returns the names of active tmux sessions.
-func ListSessions() ([]string, error) {
-	if err := CheckTmuxInstalled(); err != nil {
-		return nil, err
-	}
-
-	out, err := exec.Command("tmux", "list-sessions", "-F", "#{session_name}").CombinedOutput()
+func ListSessions(c Client) ([]string, error) {
+	out, err := c.Output("list-sessions", "-F", "#{session_name}")
 	if err != nil {
-		if strings.Contains(string(out), "no server running") {
+		if strings.Contains(err.Error(), "no server running") {
 			return nil, nil
 		}
-		return nil, fmt.Errorf("list tmux sessions: %w: %s", err, strings.TrimSpace(string(out)))
+		return nil, fmt.Errorf("list tmux sessions: %w", err)
 	}
 
 	var sessions []string
-	for _, session := range strings.Split(string(out), "\n") {
+	for _, session := range strings.Split(out, "\n") {
 		if session = strings.TrimSpace(session); session != "" {
 			sessions = append(sessions, session)
 		}
@@ -80,212 +94,161 @@ func ListSessions() ([]string, error) {
 }
 
 // StartProject creates a tmux session for the given project and optionally attaches.
-func StartProject(project cfg.Project, attach bool) error {
-	tmuxCmd := project.TmuxCommand
-	if tmuxCmd == "" {
-		tmuxCmd = "tmux"
-	}
-	if _, err := exec.LookPath(tmuxCmd); err != nil {
-		return fmt.Errorf("tmux command %q not found in PATH", tmuxCmd)
-	}
-
+func StartProject(c Client, project cfg.Project, attach bool) error {
 	// If session already exists, attach and return
-	if hasSession(tmuxCmd, project.Name) {
+	if HasSession(c, project.Name) {
 		if attach {
-			return runAttach(tmuxCmd, project.Name)
+			return AttachSession(c, project.Name)
 		}
 		return nil
 	}
 
-	// Create detached session with first window
-	firstWindowName := ""
-	if len(project.Windows) > 0 {
-		firstWindowName = project.Windows[0].Name
-	}
-	createArgs := []string{"new-session", "-d", "-s", project.Name}
-	if firstWindowName != "" {
-		createArgs = append(createArgs, "-n", firstWindowName)
-	}
-	// Use first window's root if provided, otherwise project root
-	firstRoot := ""
-	if len(project.Windows) > 0 && strings.TrimSpace(project.Windows[0].Root) != "" {
-		firstRoot = project.Windows[0].Root
+	var err error
+	if project.HasDependencies() {
+		err = runStages(c, project)
 	} else {
-		firstRoot = project.Root
+		err = runPlan(c, Plan(project))
 	}
-	if strings.TrimSpace(firstRoot) != "" {
-		createArgs = append(createArgs, "-c", cfg.ExpandPath(firstRoot))
-	}
-	if project.TmuxOptions != "" {
-		// Tmux options like -f need to be passed when invoking tmux, not subcommand
-		// For simplicity we ignore custom tmux options here; TODO in future.
+	if err != nil {
+		return err
 	}
 
-	if err := run(tmuxCmd, createArgs...); err != nil {
-		return fmt.Errorf("failed creating session: %w", err)
+	if attach {
+		return AttachSession(c, project.Name)
 	}
+	return nil
+}
 
-	// Build subsequent windows
-	for i, w := range project.Windows {
-		if i == 0 {
-			// already created with session
-			if err := setupWindow(tmuxCmd, project.Name, i, w); err != nil {
-				return err
-			}
-			continue
+// runPlan executes planned commands, pausing at each wait step until its
+// conditions hold.
+func runPlan(c Client, plan []Command) error {
+	for len(plan) > 0 {
+		n := 0
+		for n < len(plan) && plan[n].Wait == nil {
+			n++
 		}
-		args := []string{"new-window", "-t", project.Name}
-		if w.Name != "" {
-			args = append(args, "-n", w.Name)
-		}
-		// Default to project root if window root is not set
-		winRoot := w.Root
-		if strings.TrimSpace(winRoot) == "" {
-			winRoot = project.Root
-		}
-		if strings.TrimSpace(winRoot) != "" {
-			args = append(args, "-c", cfg.ExpandPath(winRoot))
-		}
-		if err := run(tmuxCmd, args...); err != nil {
-			return fmt.Errorf("failed creating window %s: %w", w.Name, err)
-		}
-		if err := setupWindow(tmuxCmd, project.Name, i, w); err != nil {
+		if err := runCommands(c, plan[:n]); err != nil {
 			return err
 		}
-	}
-
-	// Select startup window if specified
-	if project.StartupWindow != "" {
-		if err := run(tmuxCmd, "select-window", "-t", fmt.Sprintf("%s:%s", project.Name, project.StartupWindow)); err != nil {
-			return err
+		if n == len(plan) {
+			return nil
 		}
-		if project.StartupPane > 0 {
-			if err := run(tmuxCmd, "select-pane", "-t", fmt.Sprintf("%s:%s.%d", project.Name, project.StartupWindow, project.StartupPane)); err != nil {
-				return err
-			}
+		if err := waitFor(c, plan[n]); err != nil {
+			return planError(plan[n], err)
 		}
-	}
-
-	if attach {
-		return runAttach(tmuxCmd, project.Name)
+		plan = plan[n+1:]
 	}
 	return nil
 }
 
-func setupWindow(tmuxCmd, session string, index int, w cfg.Window) error {
-	target := windowTarget(session, index, w)
-	// Layout not fully supported; best effort
-	if w.Layout != "" {
-		_ = run(tmuxCmd, "select-layout", "-t", target, w.Layout)
+// errDependencyFailed marks windows skipped because a dependency failed.
+var errDependencyFailed = errors.New("dependency failed")
+
+// runStages creates every window up front so they appear in configured order,
+// then sets windows up concurrently, each once the windows it depends on are
+// ready. The first failure in window order is returned.
+func runStages(c Client, project cfg.Project) error {
+	deps, err := project.WindowDependencies()
+	if err != nil {
+		return err
+	}
+	// A cycle would leave windows waiting on each other forever
+	if _, err := project.WindowOrder(); err != nil {
+		return err
+	}
+	st := planStages(project)
+	if err := runPlan(c, st.create); err != nil {
+		return err
 	}
 
-	// If panes specified, split and run commands per pane; otherwise run window commands
-	if len(w.Panes) > 0 {
-		// Ensure we have the right number of panes
-		for i := 1; i < len(w.Panes); i++ {
-			if err := run(tmuxCmd, "split-window", "-t", target, "-h"); err != nil {
-				return err
+	done := make([]chan struct{}, len(project.Windows))
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+	errs := make([]error, len(project.Windows))
+	var wg sync.WaitGroup
+	for i := range project.Windows {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer close(done[i])
+			for _, d := range deps[i] {
+				<-done[d]
+				if errs[d] != nil {
+					errs[i] = errDependencyFailed
+					return
+				}
 			}
+			errs[i] = runPlan(c, st.setup[i])
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil && !errors.Is(err, errDependencyFailed) {
+			return err
 		}
-		// Set tiled layout to spread panes
-		_ = run(tmuxCmd, "select-layout", "-t", target, "tiled")
+	}
+	return runPlan(c, st.finish)
+}
 
-		// Run commands in each pane
-		paneBase := getPaneBaseIndex(tmuxCmd)
-		for paneIndex, pane := range w.Panes {
-			if len(pane.Commands) == 0 {
-				continue
-			}
-			for _, cmd := range pane.Commands {
-				if err := sendPaneCommand(tmuxCmd, fmt.Sprintf("%s.%d", target, paneBase+paneIndex), cmd); err != nil {
-					return err
-				}
+// runCommands executes tmux commands, in one round-trip when the client
+// supports batching. Failures of optional commands are skipped; others are
+// reported with the description of the command that caused them.
+func runCommands(c Client, plan []Command) error {
+	b, ok := c.(Batcher)
+	if !ok {
+		for _, cmd := range plan {
+			if err := c.Run(cmd.Args...); err != nil && !cmd.Optional {
+				return planError(cmd, err)
 			}
 		}
-	} else if len(w.Commands) > 0 {
-		for _, cmd := range w.Commands {
-			if err := sendPaneCommand(tmuxCmd, target, cmd); err != nil {
-				return err
-			}
+		return nil
+	}
+
+	for start := 0; start < len(plan); {
+		batch := make([][]string, 0, len(plan)-start)
+		for _, cmd := range plan[start:] {
+			batch = append(batch, cmd.Args)
+		}
+		failed, err := b.RunBatch(batch)
+		if err == nil {
+			return nil
 		}
+		cmd := plan[start+failed]
+		if !cmd.Optional {
+			return planError(cmd, err)
+		}
+		// Resume after the optional command that failed
+		start += failed + 1
 	}
 	return nil
 }
 
-// sendPaneCommand types a command into a pane and submits it.
-// Use Enter instead of C-m: on Windows psmux, C-m sends Ctrl+M (\r) as literal
-// input (^M) rather than the Enter key.
-func sendPaneCommand(tmuxCmd, target, cmd string) error {
-	cmd = strings.ReplaceAll(cmd, "\r", "")
-	return run(tmuxCmd, "send-keys", "-t", target, cmd, "Enter")
-}
-
-// windowTarget returns a tmux target for the window, preferring name to avoid base-index issues.
-func windowTarget(session string, index int, w cfg.Window) string {
-	if strings.TrimSpace(w.Name) != "" {
-		return fmt.Sprintf("%s:%s", session, w.Name)
+func planError(cmd Command, err error) error {
+	if cmd.Desc == "" {
+		return err
 	}
-	// Fallback to numeric index (may fail if base-index != 0)
-	return fmt.Sprintf("%s:%d", session, index)
+	return fmt.Errorf("%s: %w", cmd.Desc, err)
 }
 
-func runAttach(tmuxCmd, session string) error {
+// AttachSession attaches to session, or switches to it when already inside tmux.
+func AttachSession(c Client, session string) error {
 	// If already inside tmux, switch client instead of attaching
 	if os.Getenv("TMUX") != "" {
-		return run(tmuxCmd, "switch-client", "-t", session)
+		return c.Run("switch-client", "-t", "="+session)
 	}
-	c := exec.Command(tmuxCmd, "attach-session", "-t", session)
-	c.Stdin = os.Stdin
-	c.Stdout = os.Stdout
-	c.Stderr = os.Stderr
-	if err := c.Run(); err != nil {
+	if err := c.Attach("attach-session", "-t", "="+session); err != nil {
 		// Likely non-interactive shell; print hint but do not fail
-		fmt.Fprintf(os.Stderr, "Note: could not attach automatically. Run: tmux attach -t %s\n", session)
+		fmt.Fprintf(os.Stderr, "Note: could not attach automatically. Run: tmux attach -t =%s\n", session)
 		return nil
 	}
 	return nil
 }
 
-func run(name string, args ...string) error {
-	cmd := exec.Command(name, args...)
-	var out bytes.Buffer
-	var stderr bytes.Buffer
-	cmd.Stdout = &out
-	cmd.Stderr = &stderr
-	if err := cmd.Run(); err != nil {
-		if stderr.Len() > 0 {
-			return fmt.Errorf("%v: %s", err, strings.TrimSpace(stderr.String()))
-		}
-		return err
-	}
-	return nil
-}
-
-// getPaneBaseIndex returns tmux's pane-base-index (default 0 if unknown).
-func getPaneBaseIndex(tmuxCmd string) int {
-	out, err := exec.Command(tmuxCmd, "show", "-gv", "pane-base-index").CombinedOutput()
-	if err != nil {
-		return 0
-	}
-	s := strings.TrimSpace(string(out))
-	if s == "" {
-		return 0
-	}
-	v, err := strconv.Atoi(s)
-	if err != nil {
-		return 0
-	}
-	return v
-}
-
-// hasSession checks whether a tmux session exists
-func hasSession(tmuxCmd, name string) bool {
+// HasSession checks whether a tmux session of exactly this name exists.
+func HasSession(c Client, name string) bool {
 	if strings.TrimSpace(name) == "" {
 		return false
 	}
-	cmd := exec.Command(tmuxCmd, "has-session", "-t", name)
-	if err := cmd.Run(); err != nil {
-		return false
-	}
-	return true
+	return c.Run("has-session", "-t", "="+name) == nil
 }
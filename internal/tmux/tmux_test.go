@@ -1,10 +1,18 @@
 package tmux
 
 import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	cfg "github.com/sbcinnovation/lmux/internal/config"
 )
@@ -32,7 +40,7 @@ esac
 			Commands: []string{"lazygit"},
 		}},
 	}
-	if err := StartProject(project, false); err != nil {
+	if err := StartProject(&ExecClient{Bin: bin}, project, false); err != nil {
 		t.Fatalf("StartProject returned %v", err)
 	}
 
@@ -75,7 +83,7 @@ esac
 			Commands: []string{"lazygit\r"},
 		}},
 	}
-	if err := StartProject(project, false); err != nil {
+	if err := StartProject(&ExecClient{Bin: bin}, project, false); err != nil {
 		t.Fatalf("StartProject returned %v", err)
 	}
 	args, err := os.ReadFile(argsFile)
@@ -98,7 +106,572 @@ func TestStartProjectUsesConfiguredTmuxCommand(t *testing.T) {
 		TmuxCommand: bin,
 		Windows:     []cfg.Window{{Name: "app"}},
 	}
-	if err := StartProject(project, false); err != nil {
+	client, err := NewExecClient(project.TmuxCommand)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := StartProject(client, project, false); err != nil {
+		t.Fatalf("StartProject returned %v", err)
+	}
+}
+
+func TestPlanTypesPaneCommandsAfterEachSplit(t *testing.T) {
+	project := cfg.Project{
+		Name: "proj",
+		Root: "/src/proj",
+		Windows: []cfg.Window{
+			{Name: "editor", Panes: []cfg.Pane{{Commands: []string{"vim"}}, {Commands: []string{"go test ./..."}}}},
+			{Name: "logs", Root: "/var/log", Commands: []string{"tail -f syslog"}},
+		},
+	}
+	var got []string
+	for _, c := range Plan(project) {
+		got = append(got, strings.Join(c.Args, " "))
+	}
+	want := []string{
+		"new-session -d -s proj -n editor -c /src/proj",
+		"send-keys -t proj:editor vim Enter",
+		"split-window -t proj:editor -h",
+		"select-layout -t proj:editor tiled",
+		"send-keys -t proj:editor go test ./... Enter",
+		"new-window -t proj: -n logs -c /var/log",
+		"send-keys -t proj:logs tail -f syslog Enter",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("Plan() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestScriptQuotesCommands(t *testing.T) {
+	project := cfg.Project{
+		Name:    "proj",
+		Windows: []cfg.Window{{Name: "app", Commands: []string{"echo 'hi there'"}}},
+	}
+	script, err := Script(project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(script, "#!/bin/sh\n") {
+		t.Fatalf("script should start with a POSIX shebang; got %q", script)
+	}
+	if want := `"$TMUX_BIN" send-keys -t proj:app 'echo '\''hi there'\''' Enter`; !strings.Contains(script, want) {
+		t.Fatalf("script missing quoted send-keys line %q; got\n%s", want, script)
+	}
+	if !strings.Contains(script, `attach-session -t proj`) {
+		t.Fatalf("script should attach to the session; got\n%s", script)
+	}
+}
+
+func TestExtendedRegexpMatchesLikeGoWithGrep(t *testing.T) {
+	if _, err := exec.LookPath("grep"); err != nil {
+		t.Skip("grep is not installed")
+	}
+	patterns := []string{
+		`^listening on :\d+`,
+		`ready\s+in \d+(\.\d+)?ms`,
+		`(?i)compiled successfully`,
+		`[^\w\s]{2,}`,
+		`a.*?b|^\$ $`,
+		`[\]\-^]x`,
+		`[^-]{3}$`,
+		`(err|warn)(or|ing):`,
+	}
+	lines := []string{"listening on :8080", "  ready   in 12.5ms", "webpack COMPILED Successfully", "a -> b", "$ ", "^x", "]x", "error: boom", "warning:", "abc", "--"}
+	for _, pattern := range patterns {
+		ere, err := extendedRegexp("(?m)" + pattern)
+		if err != nil {
+			t.Fatalf("extendedRegexp(%q): %v", pattern, err)
+		}
+		re := regexp.MustCompile("(?m)" + pattern)
+		for _, line := range lines {
+			grep := exec.Command("grep", "-Eq", "--", ere)
+			grep.Stdin = strings.NewReader(line + "\n")
+			if got, want := grep.Run() == nil, re.MatchString(line); got != want {
+				t.Errorf("grep -E %q on %q = %v, want %v as /%s/ gives", ere, line, got, want, pattern)
+			}
+		}
+	}
+
+	for _, pattern := range []string{`\bready\b`, `\Aready`, `ready\nset`, `ready|`} {
+		if ere, err := extendedRegexp(pattern); err == nil {
+			t.Errorf("extendedRegexp(%q) = %q, want an error", pattern, ere)
+		}
+	}
+}
+
+func TestScriptChecksForNcAndRejectsUnportablePatterns(t *testing.T) {
+	project := cfg.Project{
+		Name: "proj",
+		Windows: []cfg.Window{
+			{Name: "db", WaitFor: &cfg.WaitFor{Port: 5432, Host: "localhost", Timeout: time.Second}},
+			{Name: "app", Commands: []string{"make run"}},
+		},
+	}
+	script, err := Script(project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(script, "command -v nc") {
+		t.Fatalf("script waits on a port without checking for nc:\n%s", script)
+	}
+
+	project.Windows[0].WaitFor = &cfg.WaitFor{Output: regexp.MustCompile(`(?m)\bready\b`), Timeout: time.Second}
+	if _, err := Script(project); err == nil || !strings.Contains(err.Error(), "word boundaries") {
+		t.Fatalf("Script() error = %v, want the pattern rejected", err)
+	}
+}
+
+func TestStartProjectRecordsCommandsWithFake(t *testing.T) {
+	fake := NewFake()
+	project := cfg.Project{
+		Name:    "proj",
+		Windows: []cfg.Window{{Name: "app", Commands: []string{"make run"}}},
+	}
+	if err := StartProject(fake, project, false); err != nil {
 		t.Fatalf("StartProject returned %v", err)
 	}
+	want := []string{
+		"has-session -t =proj",
+		"new-session -d -s proj -n app",
+		"send-keys -t proj:app make run Enter",
+	}
+	if got := fake.Commands(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("commands = %q, want %q", got, want)
+	}
+	if sessions, _ := ListSessions(fake); len(sessions) != 1 || sessions[0] != "proj" {
+		t.Fatalf("ListSessions() = %q, want [proj]", sessions)
+	}
+}
+
+func TestStartProjectAttributesErrorsToWindow(t *testing.T) {
+	fake := NewFake()
+	fake.Errors = map[string]error{"new-window": errors.New("boom")}
+	project := cfg.Project{
+		Name:    "proj",
+		Windows: []cfg.Window{{Name: "app"}, {Name: "logs"}},
+	}
+	err := StartProject(fake, project, false)
+	if err == nil || !strings.Contains(err.Error(), "window logs") {
+		t.Fatalf("StartProject error = %v, want it to name window logs", err)
+	}
+}
+
+func TestKillSessionWithFake(t *testing.T) {
+	fake := NewFake("proj", "other")
+	if err := KillSession(fake, "proj"); err != nil {
+		t.Fatal(err)
+	}
+	sessions, err := ListSessions(fake)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 || sessions[0] != "other" {
+		t.Fatalf("ListSessions() = %q, want [other]", sessions)
+	}
+}
+
+func TestStartProjectBuildsSessionInOneInvocation(t *testing.T) {
+	dir := t.TempDir()
+	callsFile := filepath.Join(dir, "calls")
+	bin := filepath.Join(dir, "fake-tmux")
+	script := `#!/bin/sh
+case "$1" in
+has-session) exit 1 ;;
+*) echo call >> "` + callsFile + `" ;;
+esac
+`
+	if err := os.WriteFile(bin, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	project := cfg.Project{
+		Name: "proj",
+		Windows: []cfg.Window{
+			{Name: "editor", Panes: []cfg.Pane{{Commands: []string{"vim"}}, {Commands: []string{"bash"}}}},
+			{Name: "logs", Commands: []string{"tail -f log"}},
+		},
+	}
+	if err := StartProject(&ExecClient{Bin: bin}, project, false); err != nil {
+		t.Fatalf("StartProject returned %v", err)
+	}
+	calls, err := os.ReadFile(callsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Count(string(calls), "call"); got != 1 {
+		t.Fatalf("tmux invoked %d times to build the session, want 1", got)
+	}
+}
+
+func TestRunBatchReportsFailingCommand(t *testing.T) {
+	bin := filepath.Join(t.TempDir(), "fake-tmux")
+	// Pretend the first two commands ran before tmux hit an error
+	script := "#!/bin/sh\necho 'lmux-batch-done 0'\necho 'lmux-batch-done 1'\necho \"can't find window: logs\" >&2\nexit 1\n"
+	if err := os.WriteFile(bin, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	project := cfg.Project{
+		Name:    "proj",
+		Windows: []cfg.Window{{Name: "app", Commands: []string{"make"}}, {Name: "logs", Commands: []string{"tail -f log"}}},
+	}
+	err := runPlan(&ExecClient{Bin: bin}, Plan(project))
+	if err == nil || !strings.Contains(err.Error(), "failed creating window logs") {
+		t.Fatalf("runPlan error = %v, want it attributed to window logs", err)
+	}
+}
+
+func TestEscapeSeparatorKeepsTrailingSemicolon(t *testing.T) {
+	if got, want := escapeSeparator("echo a;"), `echo a\;`; got != want {
+		t.Fatalf("escapeSeparator() = %q, want %q", got, want)
+	}
+	if got := escapeSeparator("echo a"); got != "echo a" {
+		t.Fatalf("escapeSeparator() changed %q", got)
+	}
+}
+
+// fakeControlServer answers each command line written by a ControlClient with
+// the next scripted reply, mimicking tmux's control-mode framing.
+func fakeControlServer(t *testing.T, replies []string) (*ControlClient, *[]string, []Notification) {
+	t.Helper()
+	cmdR, cmdW := io.Pipe()
+	outR, outW := io.Pipe()
+	var received []string
+	notes := make(chan Notification, 16)
+	c := newControlClient(outR, cmdW, func(n Notification) { notes <- n }, 0)
+	go func() {
+		defer cmdR.Close()
+		defer outW.Close()
+		_, _ = io.WriteString(outW, "%window-add @3\n%output %1 hi\\015\\012\n")
+		scanner := bufio.NewScanner(cmdR)
+		for i := 0; scanner.Scan() && i < len(replies); i++ {
+			received = append(received, scanner.Text())
+			fmt.Fprintf(outW, "%%begin 1 %d 1\n%s%%end 1 %d 1\n", i, replies[i], i)
+		}
+	}()
+	t.Cleanup(func() { cmdW.Close() })
+	// Both notifications precede any reply, so they have arrived once the first command returns
+	if _, err := c.Output("display-message", "-p", "ready"); err != nil {
+		t.Fatal(err)
+	}
+	return c, &received, []Notification{<-notes, <-notes}
+}
+
+func TestControlClientParsesRepliesAndNotifications(t *testing.T) {
+	c, received, notes := fakeControlServer(t, []string{"", "%0 proj\n%1 proj\n"})
+	out, err := c.Output("list-panes", "-F", "it's #{pane_id}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "%0 proj\n%1 proj\n" {
+		t.Fatalf("Output() = %q, want pane lines starting with %%", out)
+	}
+	if got, want := (*received)[1], `list-panes -F 'it'\''s #{pane_id}'`; got != want {
+		t.Fatalf("command line = %q, want %q", got, want)
+	}
+	if notes[0].Name != "window-add" || notes[0].Args[0] != "@3" {
+		t.Fatalf("first notification = %+v, want window-add @3", notes[0])
+	}
+	if notes[1].Name != "output" || notes[1].Args[0] != "%1" || notes[1].Data != "hi\r\n" {
+		t.Fatalf("second notification = %+v, want unescaped output for %%1", notes[1])
+	}
+}
+
+func TestControlClientReportsErrorBlocks(t *testing.T) {
+	cmdR, cmdW := io.Pipe()
+	outR, outW := io.Pipe()
+	c := newControlClient(outR, cmdW, nil, 0)
+	go func() {
+		defer cmdR.Close()
+		defer outW.Close()
+		scanner := bufio.NewScanner(cmdR)
+		scanner.Scan()
+		_, _ = io.WriteString(outW, "%begin 1 7 1\ncan't find window: nope\n%error 1 7 1\n")
+	}()
+	defer cmdW.Close()
+	err := c.Run("select-window", "-t", "nope")
+	if err == nil || err.Error() != "can't find window: nope" {
+		t.Fatalf("Run() error = %v, want tmux's error text", err)
+	}
+	if err := c.Run("display-message", "-p", "x"); !errors.Is(err, errControlClosed) {
+		t.Fatalf("Run() after close = %v, want errControlClosed", err)
+	}
+}
+
+func TestControlClientDropsUnsolicitedBlocks(t *testing.T) {
+	cmdR, cmdW := io.Pipe()
+	outR, outW := io.Pipe()
+	notes := make(chan Notification, 4)
+	c := newControlClient(outR, cmdW, func(n Notification) { notes <- n }, 0)
+	go func() {
+		defer cmdR.Close()
+		defer outW.Close()
+		// A hook's block arrives with no command waiting for it
+		_, _ = io.WriteString(outW, "%begin 1 3 0\nfrom a hook\n%end 1 3 0\n%window-add @1\n")
+		scanner := bufio.NewScanner(cmdR)
+		scanner.Scan()
+		_, _ = io.WriteString(outW, "%begin 1 4 1\nready\n%end 1 4 1\n%window-add @2\n")
+	}()
+	defer cmdW.Close()
+	out, err := c.Output("display-message", "-p", "ready")
+	if err != nil || out != "ready\n" {
+		t.Fatalf("Output() = %q, %v; want the command's own reply", out, err)
+	}
+	for _, want := range []string{"@1", "@2"} {
+		select {
+		case n := <-notes:
+			if n.Args[0] != want {
+				t.Fatalf("notification = %+v, want window-add %s", n, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("no notification for %s: the reader stopped", want)
+		}
+	}
+}
+
+// useTmuxServer points tmux at a private server for the test, skipping it
+// when tmux is not installed.
+func useTmuxServer(t *testing.T) *ExecClient {
+	t.Helper()
+	if _, err := exec.LookPath("tmux"); err != nil {
+		t.Skip("tmux is not installed")
+	}
+	t.Setenv("TMUX_TMPDIR", t.TempDir())
+	t.Setenv("TMUX", "")
+	client := &ExecClient{Bin: "tmux"}
+	t.Cleanup(func() { _ = client.Run("kill-server") })
+	return client
+}
+
+func TestDialControlRunsCommandsOnRealTmux(t *testing.T) {
+	client := useTmuxServer(t)
+	if err := client.Run("new-session", "-d", "-s", "ctl"); err != nil {
+		t.Fatal(err)
+	}
+	notes := make(chan Notification, 16)
+	c, err := DialControl("tmux", []string{"attach-session", "-t", "=ctl"}, func(n Notification) { notes <- n })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := c.Run("new-window", "-t", "=ctl:", "-n", "it's two"); err != nil {
+		t.Fatal(err)
+	}
+	out, err := c.Output("list-windows", "-t", "=ctl", "-F", "#{window_name}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(out, "\nit's two\n") {
+		t.Fatalf("list-windows = %q, want the new window last", out)
+	}
+	if err := c.Run("select-window", "-t", "=ctl:nope"); err == nil || !strings.Contains(err.Error(), "nope") {
+		t.Fatalf("select-window error = %v, want tmux's error", err)
+	}
+	deadline := time.After(2 * time.Second)
+	for {
+		select {
+		case n := <-notes:
+			if n.Name == "window-add" {
+				return
+			}
+		case <-deadline:
+			t.Fatal("no window-add notification for the new window")
+		}
+	}
+}
+
+func TestStartProjectOverControlBackend(t *testing.T) {
+	client := useTmuxServer(t)
+	project := cfg.Project{
+		Name: "proj",
+		Windows: []cfg.Window{
+			{Name: "server", Commands: []string{"echo listening"}, WaitFor: &cfg.WaitFor{Output: regexp.MustCompile("(?m)^listening"), Timeout: 5 * time.Second}},
+			{Name: "logs", Panes: []cfg.Pane{{Commands: []string{"echo a"}}, {Commands: []string{"echo b"}}}},
+		},
+	}
+	b := NewControlBackend(&ExecClient{Bin: "tmux"}, nil)
+	if err := StartProject(b, project, false); err != nil {
+		t.Fatal(err)
+	}
+	if !b.Connected() {
+		t.Fatal("ControlBackend did not connect after creating the session")
+	}
+	out, err := client.Output("list-panes", "-s", "-t", "=proj", "-F", "#{window_name}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "server\nlogs\nlogs\n" {
+		t.Fatalf("panes = %q, want server and two logs panes", out)
+	}
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if clients, _ := client.Output("list-clients"); clients != "" {
+		t.Fatalf("clients after Close = %q, want the control client gone", clients)
+	}
+}
+
+func TestControlBackendDialsOnceForConcurrentSessions(t *testing.T) {
+	client := useTmuxServer(t)
+	b := NewControlBackend(&ExecClient{Bin: "tmux"}, nil)
+	var wg sync.WaitGroup
+	for _, name := range []string{"a", "b", "c"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := b.Run("new-session", "-d", "-s", name); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if clients, _ := client.Output("list-clients", "-F", "#{client_control_mode}"); clients != "1\n" {
+		t.Fatalf("control clients = %q, want one connection", clients)
+	}
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if clients, _ := client.Output("list-clients"); clients != "" {
+		t.Fatalf("clients after Close = %q, want the control client gone", clients)
+	}
+}
+
+func TestFlagsSelectTheServerForProcessesAndControl(t *testing.T) {
+	useTmuxServer(t)
+	client := &ExecClient{Bin: "tmux", Flags: []string{"-L", "lmux-flags"}}
+	t.Cleanup(func() { _ = client.Run("kill-server") })
+	b := NewControlBackend(client, nil)
+	if err := b.Run("new-session", "-d", "-s", "flags"); err != nil {
+		t.Fatal(err)
+	}
+	if !b.Connected() {
+		t.Fatal("control connection not opened on the flagged server")
+	}
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !HasSession(client, "flags") {
+		t.Fatal("session missing on the flagged server")
+	}
+	if HasSession(&ExecClient{Bin: "tmux"}, "flags") {
+		t.Fatal("session built on the default server")
+	}
+
+	project := cfg.Project{Name: "flags", TmuxFlags: client.Flags, Windows: []cfg.Window{{Name: "app"}}}
+	script, err := Script(project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `"$TMUX_BIN" -L lmux-flags new-session -d -s flags`; !strings.Contains(script, want) {
+		t.Fatalf("script missing %q; got\n%s", want, script)
+	}
+	if got, want := FormatCommand("tmux", client.Flags, Plan(project)[0]), "tmux -L lmux-flags new-session -d -s flags -n app"; got != want {
+		t.Fatalf("FormatCommand() = %q, want %q", got, want)
+	}
+}
+
+func TestStartProjectWaitsForPaneOutputBeforeLaterWindows(t *testing.T) {
+	fake := NewFake()
+	fake.Outputs = map[string]string{"capture-pane": "$ go run .\nlistening on :8080\n"}
+	project := cfg.Project{
+		Name: "proj",
+		Windows: []cfg.Window{
+			{Name: "server", Commands: []string{"go run ."}, WaitFor: &cfg.WaitFor{Output: regexp.MustCompile("(?m)^listening"), Timeout: time.Second}},
+			{Name: "e2e", Commands: []string{"make e2e"}},
+		},
+	}
+	if err := StartProject(fake, project, false); err != nil {
+		t.Fatalf("StartProject returned %v", err)
+	}
+	got := strings.Join(fake.Commands(), "\n")
+	want := "send-keys -t proj:server go run . Enter\ncapture-pane -p -t proj:server\nnew-window -t proj: -n e2e"
+	if !strings.Contains(got, want) {
+		t.Fatalf("commands =\n%s\nwant e2e created only after capturing server output", got)
+	}
+}
+
+func TestStartProjectTimesOutWaitingForFile(t *testing.T) {
+	fake := NewFake()
+	dir := t.TempDir()
+	project := cfg.Project{
+		Name: "proj",
+		Root: dir,
+		Windows: []cfg.Window{
+			{Name: "build", WaitFor: &cfg.WaitFor{File: "ready", Timeout: 10 * time.Millisecond}},
+			{Name: "app"},
+		},
+	}
+	err := StartProject(fake, project, false)
+	if err == nil || !strings.Contains(err.Error(), "window build: timed out") {
+		t.Fatalf("StartProject error = %v, want a timeout for window build", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "ready"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := StartProject(NewFake(), project, false); err != nil {
+		t.Fatalf("StartProject with the file present returned %v", err)
+	}
+}
+
+func TestStartProjectTimesOutWaitingForHungCommand(t *testing.T) {
+	project := cfg.Project{
+		Name:    "proj",
+		Windows: []cfg.Window{{Name: "api", WaitFor: &cfg.WaitFor{Command: "sleep 30", Timeout: 200 * time.Millisecond}}},
+	}
+	start := time.Now()
+	err := StartProject(NewFake(), project, false)
+	if err == nil || !strings.Contains(err.Error(), "window api: timed out") {
+		t.Fatalf("StartProject error = %v, want a timeout for window api", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("StartProject took %s, want the hung command killed at the timeout", elapsed)
+	}
+}
+
+func TestStartProjectSetsUpWindowsAfterTheirDependencies(t *testing.T) {
+	fake := NewFake()
+	fake.Outputs = map[string]string{"capture-pane": "ready\n"}
+	ready := &cfg.WaitFor{Output: regexp.MustCompile("(?m)^ready"), Timeout: time.Second}
+	project := cfg.Project{
+		Name: "proj",
+		Windows: []cfg.Window{
+			{Name: "e2e", Commands: []string{"make e2e"}, DependsOn: []string{"server"}},
+			{Name: "server", Commands: []string{"make serve"}, DependsOn: []string{"db"}, WaitFor: ready},
+			{Name: "db", Commands: []string{"make db"}, WaitFor: ready},
+		},
+	}
+	if err := StartProject(fake, project, false); err != nil {
+		t.Fatalf("StartProject returned %v", err)
+	}
+	cmds := fake.Commands()
+	position := func(cmd string) int {
+		for i, c := range cmds {
+			if c == cmd {
+				return i
+			}
+		}
+		t.Fatalf("missing command %q in %q", cmd, cmds)
+		return -1
+	}
+	if position("new-window -t proj: -n db") > position("send-keys -t proj:db make db Enter") {
+		t.Fatalf("windows should all be created before any is set up: %q", cmds)
+	}
+	if !(position("capture-pane -p -t proj:db") < position("send-keys -t proj:server make serve Enter") &&
+		position("capture-pane -p -t proj:server") < position("send-keys -t proj:e2e make e2e Enter")) {
+		t.Fatalf("windows were set up before their dependencies were ready: %q", cmds)
+	}
+}
+
+func TestCapturePaneKeepsLastLinesAboveCursor(t *testing.T) {
+	fake := NewFake("api")
+	fake.Outputs = map[string]string{"capture-pane": "one\ntwo\nthree\n\n\n\n"}
+	lines, err := CapturePane(fake, "=api:", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(lines, ","); got != "two,three" {
+		t.Fatalf("CapturePane() = %q, want two,three", got)
+	}
+	if got := fake.Commands()[0]; got != "capture-pane -p -J -t =api: -S -2" {
+		t.Fatalf("command = %q", got)
+	}
 }
@@ -0,0 +1,91 @@
+package tmux
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"time"
+
+	cfg "github.com/sbcinnovation/lmux/internal/config"
+)
+
+// pollInterval is how often wait_for conditions are checked again.
+var pollInterval = 250 * time.Millisecond
+
+// waitFor blocks until every condition of the wait step holds or its timeout expires.
+func waitFor(c Client, step Command) error {
+	deadline := time.Now().Add(step.Wait.Timeout)
+	for {
+		ready, err := waitReady(c, step, deadline)
+		if err != nil {
+			return err
+		}
+		if ready {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out after %s waiting for %s", step.Wait.Timeout, step.Wait)
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+// waitReady checks the wait step's conditions once. A command still running
+// at deadline is killed and counts as not ready.
+func waitReady(c Client, step Command, deadline time.Time) (bool, error) {
+	w := step.Wait
+	if w.Port != 0 {
+		conn, err := net.DialTimeout("tcp", net.JoinHostPort(w.Host, strconv.Itoa(w.Port)), pollInterval)
+		if err != nil {
+			return false, nil
+		}
+		conn.Close()
+	}
+	if w.File != "" {
+		if _, err := os.Stat(waitPath(step)); err != nil {
+			return false, nil
+		}
+	}
+	if w.Command != "" {
+		ctx, cancel := context.WithDeadline(context.Background(), deadline)
+		defer cancel()
+		cmd := shellCommand(ctx, w.Command)
+		cmd.Dir = step.Dir
+		if err := cmd.Run(); err != nil {
+			return false, nil
+		}
+	}
+	if w.Output != nil {
+		out, err := c.Output("capture-pane", "-p", "-t", step.Target)
+		if err != nil {
+			// The pane is gone, so its output will never match
+			return false, fmt.Errorf("capture output of %s: %w", step.Target, err)
+		}
+		if !w.Output.MatchString(out) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// waitPath resolves a wait step's file against its directory.
+func waitPath(step Command) string {
+	path := cfg.ExpandPath(step.Wait.File)
+	if !filepath.IsAbs(path) && step.Dir != "" {
+		path = filepath.Join(step.Dir, path)
+	}
+	return path
+}
+
+// shellCommand runs s through the platform shell until ctx is done.
+func shellCommand(ctx context.Context, s string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", s)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", s)
+}
@@ -0,0 +1,223 @@
+// Package tui holds the terminal plumbing shared by lmux's interactive
+// screens: raw mode, the alternate screen and keypress decoding.
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// Key is a decoded keypress.
+type Key struct {
+	Rune rune // printable character, or 0 for special keys
+	Name string
+}
+
+// Special key names.
+const (
+	KeyEnter     = "enter"
+	KeyEscape    = "escape"
+	KeyBackspace = "backspace"
+	KeyUp        = "up"
+	KeyDown      = "down"
+	KeyClear     = "clear"
+	KeyRefresh   = "refresh"
+)
+
+// DecodeKeys turns raw terminal input into keypresses.
+func DecodeKeys(b []byte) []Key {
+	var keys []Key
+	for len(b) > 0 {
+		switch {
+		case b[0] == 0x1b && len(b) >= 3 && (b[1] == '[' || b[1] == 'O'):
+			switch b[2] {
+			case 'A':
+				keys = append(keys, Key{Name: KeyUp})
+			case 'B':
+				keys = append(keys, Key{Name: KeyDown})
+			}
+			b = b[3:]
+		case b[0] == 0x1b:
+			keys = append(keys, Key{Name: KeyEscape})
+			b = b[1:]
+		case b[0] == '\r' || b[0] == '\n':
+			keys = append(keys, Key{Name: KeyEnter})
+			b = b[1:]
+		case b[0] == 0x7f || b[0] == 0x08:
+			keys = append(keys, Key{Name: KeyBackspace})
+			b = b[1:]
+		case b[0] == 0x03: // Ctrl-C
+			keys = append(keys, Key{Name: KeyEscape})
+			b = b[1:]
+		case b[0] == 0x10 || b[0] == 0x0b: // Ctrl-P, Ctrl-K
+			keys = append(keys, Key{Name: KeyUp})
+			b = b[1:]
+		case b[0] == 0x0e: // Ctrl-N
+			keys = append(keys, Key{Name: KeyDown})
+			b = b[1:]
+		case b[0] == 0x15: // Ctrl-U
+			keys = append(keys, Key{Name: KeyClear})
+			b = b[1:]
+		case b[0] == 0x0c: // Ctrl-L
+			keys = append(keys, Key{Name: KeyRefresh})
+			b = b[1:]
+		case b[0] < 0x20:
+			b = b[1:]
+		default:
+			r, size := utf8.DecodeRune(b)
+			keys = append(keys, Key{Rune: r})
+			b = b[size:]
+		}
+	}
+	return keys
+}
+
+// Terminal is a terminal in raw mode showing the alternate screen.
+type Terminal struct {
+	in    *os.File
+	keys  *os.File // where keys are read from; supports deadlines if it is /dev/tty
+	out   io.Writer
+	fd    int
+	state *term.State
+}
+
+// Open switches the terminal behind in to raw mode and the alternate screen,
+// so the shell's scrollback is left untouched. It fails if in is not a
+// terminal.
+func Open(in *os.File, out io.Writer) (*Terminal, error) {
+	fd := int(in.Fd())
+	if !term.IsTerminal(fd) {
+		return nil, errors.New("interactive mode needs a terminal")
+	}
+	t := &Terminal{in: in, keys: in, out: out, fd: fd}
+	// Standard input does not support read deadlines, the controlling
+	// terminal opened afresh does
+	if tty, err := os.Open("/dev/tty"); err == nil {
+		if tty.SetReadDeadline(time.Time{}) == nil {
+			t.keys = tty
+		} else {
+			tty.Close()
+		}
+	}
+	if err := t.Resume(); err != nil {
+		t.closeKeys()
+		return nil, err
+	}
+	return t, nil
+}
+
+// Size returns the terminal's width and height, defaulting to 80x24.
+func (t *Terminal) Size() (int, int) {
+	width, height, err := term.GetSize(t.fd)
+	if err != nil || width == 0 || height == 0 {
+		return 80, 24
+	}
+	return width, height
+}
+
+// Draw replaces the screen with lines and leaves the cursor at the given
+// zero-based row and column.
+func (t *Terminal) Draw(lines []string, row, col int) {
+	var b strings.Builder
+	b.WriteString("\x1b[H\x1b[2J")
+	for i, l := range lines {
+		if i > 0 {
+			b.WriteString("\r\n")
+		}
+		b.WriteString(l)
+	}
+	fmt.Fprintf(&b, "\x1b[%d;%dH", row+1, col+1)
+	io.WriteString(t.out, b.String())
+}
+
+// ReadKeys waits for input and returns the keys pressed. With a positive
+// timeout it returns no keys once the timeout passes, where the terminal
+// supports it.
+func (t *Terminal) ReadKeys(timeout time.Duration) ([]Key, error) {
+	if timeout > 0 && t.keys != t.in {
+		if err := t.keys.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+			return nil, err
+		}
+	}
+	buf := make([]byte, 64)
+	n, err := t.keys.Read(buf)
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return DecodeKeys(buf[:n]), nil
+}
+
+// Suspend hands the terminal back, e.g. to run an editor or attach to tmux.
+func (t *Terminal) Suspend() error {
+	if t.state == nil {
+		return nil
+	}
+	fmt.Fprint(t.out, "\x1b[?1049l")
+	err := term.Restore(t.fd, t.state)
+	t.state = nil
+	return err
+}
+
+// Resume takes the terminal back after Suspend.
+func (t *Terminal) Resume() error {
+	if t.state != nil {
+		return nil
+	}
+	state, err := term.MakeRaw(t.fd)
+	if err != nil {
+		return err
+	}
+	t.state = state
+	fmt.Fprint(t.out, "\x1b[?1049h")
+	return nil
+}
+
+// Close restores the terminal.
+func (t *Terminal) Close() error {
+	err := t.Suspend()
+	t.closeKeys()
+	return err
+}
+
+func (t *Terminal) closeKeys() {
+	if t.keys != t.in {
+		t.keys.Close()
+	}
+}
+
+// VisibleLen returns the number of runes in s not counting ANSI escapes.
+func VisibleLen(s string) int {
+	n := 0
+	for i := 0; i < len(s); {
+		if s[i] == 0x1b {
+			for i < len(s) && !(s[i] >= 'A' && s[i] <= 'Z' || s[i] >= 'a' && s[i] <= 'z') {
+				i++
+			}
+			i++
+			continue
+		}
+		_, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+		n++
+	}
+	return n
+}
+
+// Truncate shortens s to width runes, marking the cut with an ellipsis.
+func Truncate(s string, width int) string {
+	if width <= 0 || utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	r := []rune(s)
+	return string(r[:width-1]) + "…"
+}
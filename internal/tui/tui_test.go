@@ -0,0 +1,29 @@
+package tui
+
+import "testing"
+
+func TestDecodeKeys(t *testing.T) {
+	keys := DecodeKeys([]byte("a\x1b[A\x1bOB\r\x7f\x03\x10\x0e\x15\x0cé\x1b"))
+	want := []Key{
+		{Rune: 'a'}, {Name: KeyUp}, {Name: KeyDown}, {Name: KeyEnter}, {Name: KeyBackspace},
+		{Name: KeyEscape}, {Name: KeyUp}, {Name: KeyDown}, {Name: KeyClear}, {Name: KeyRefresh},
+		{Rune: 'é'}, {Name: KeyEscape},
+	}
+	if len(keys) != len(want) {
+		t.Fatalf("decoded %d keys %v, want %d", len(keys), keys, len(want))
+	}
+	for i := range want {
+		if keys[i] != want[i] {
+			t.Errorf("key %d = %+v, want %+v", i, keys[i], want[i])
+		}
+	}
+}
+
+func TestVisibleLenSkipsEscapes(t *testing.T) {
+	if n := VisibleLen("\x1b[7m> ● api\x1b[0m"); n != 7 {
+		t.Fatalf("VisibleLen = %d, want 7", n)
+	}
+	if got := Truncate("backend-api", 6); got != "backe…" {
+		t.Fatalf("Truncate = %q, want backe…", got)
+	}
+}
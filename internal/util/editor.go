@@ -2,53 +2,293 @@
 package util
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/term"
 
 	cfg "github.com/sbcinnovation/lmux/internal/config"
+	"github.com/sbcinnovation/lmux/internal/shell"
+	"github.com/sbcinnovation/lmux/internal/tmux"
 )
 
-// OpenInEditor opens the given file path in $EDITOR if set, otherwise uses open -t on macOS.
-func OpenInEditor(path string) error {
-	// Prefer persisted settings
-	var editor string
+// Editor is the command lmux opens files with.
+type Editor struct {
+	// Command is the editor as configured, parsed into words like a shell
+	// would. It may use {file} and {line} placeholders, e.g. "nvim +{line}".
+	Command string
+	// Source is where the editor came from: "settings", "env" or "default".
+	Source string
+}
+
+// ResolveEditor picks the editor to use: the one saved in settings, then
+// $VISUAL, then $EDITOR, then a platform default (open -t on macOS;
+// xdg-open in a graphical session, sensible-editor or vi elsewhere).
+func ResolveEditor() (Editor, error) {
 	if settings, err := cfg.LoadSettings(); err == nil {
-		editor = strings.TrimSpace(settings.Editor)
-	}
-	// If no persisted editor, fall back to $EDITOR and persist it for future runs
-	if editor == "" {
-		if env := strings.TrimSpace(os.Getenv("EDITOR")); env != "" {
-			editor = env
-			// Best-effort persistence; ignore errors
-			if settings, err := cfg.LoadSettings(); err == nil {
-				settings.Editor = editor
-				_ = cfg.SaveSettings(settings)
+		if editor := strings.TrimSpace(settings.Editor); editor != "" {
+			return Editor{Command: editor, Source: "settings"}, nil
+		}
+	}
+	for _, name := range []string{"VISUAL", "EDITOR"} {
+		if editor := strings.TrimSpace(os.Getenv(name)); editor != "" {
+			return Editor{Command: editor, Source: "env"}, nil
+		}
+	}
+	var defaults []string
+	if runtime.GOOS == "darwin" {
+		defaults = append(defaults, "open -t")
+	} else if os.Getenv("DISPLAY") != "" || os.Getenv("WAYLAND_DISPLAY") != "" {
+		defaults = append(defaults, "xdg-open")
+	}
+	defaults = append(defaults, "sensible-editor", "vi")
+	for _, editor := range defaults {
+		if _, err := exec.LookPath(strings.Fields(editor)[0]); err == nil {
+			return Editor{Command: editor, Source: "default"}, nil
+		}
+	}
+	return Editor{}, errors.New("no editor found; set $VISUAL or $EDITOR, or run: lmux editor <command>")
+}
+
+// Args returns the command line that opens path, with the cursor on line
+// when line is positive. {file} and {line} in the command are replaced;
+// words using {line} are dropped when there is no line, and path is
+// appended when the command has no {file}.
+func (e Editor) Args(path string, line int) ([]string, error) {
+	words, err := shell.Split(e.Command)
+	if err != nil {
+		return nil, fmt.Errorf("editor %q: %w", e.Command, err)
+	}
+	if len(words) == 0 {
+		return nil, fmt.Errorf("invalid editor command %q", e.Command)
+	}
+	args := make([]string, 0, len(words)+1)
+	hasFile := false
+	for _, w := range words {
+		if strings.Contains(w, "{line}") {
+			if line <= 0 {
+				continue
 			}
+			w = strings.ReplaceAll(w, "{line}", strconv.Itoa(line))
+		}
+		if strings.Contains(w, "{file}") {
+			w = strings.ReplaceAll(w, "{file}", path)
+			hasFile = true
 		}
+		args = append(args, w)
 	}
-	if editor != "" {
-		// Support EDITOR commands with arguments (e.g., "code -w")
-		parts := strings.Fields(editor)
-		if len(parts) == 0 {
-			return fmt.Errorf("invalid editor command")
+	if !hasFile {
+		args = append(args, path)
+	}
+	return args, nil
+}
+
+// Detached reports whether the editor hands the file to another program and
+// returns before it is closed, as xdg-open and open do.
+func (e Editor) Detached() bool {
+	words, err := shell.Split(e.Command)
+	if err != nil || len(words) == 0 {
+		return false
+	}
+	switch filepath.Base(words[0]) {
+	case "xdg-open", "gio":
+		return true
+	case "open":
+		for _, w := range words[1:] {
+			if w == "-W" || w == "--wait-apps" {
+				return false
+			}
 		}
-		cmd := exec.Command(parts[0], append(parts[1:], path)...)
+		return true
+	}
+	return false
+}
+
+// OpenInEditor opens path in the resolved editor and waits for it.
+func OpenInEditor(path string) error {
+	return OpenInEditorAt(path, 0)
+}
+
+// OpenInEditorAt opens path in the resolved editor at line, or at the top
+// when line is 0. Editors that return straight away are waited for by asking
+// the user to press Enter when stdin is a terminal.
+func OpenInEditorAt(path string, line int) error {
+	editor, err := ResolveEditor()
+	if err != nil {
+		return err
+	}
+	args, err := editor.Args(path, line)
+	if err != nil {
+		return err
+	}
+	editIn, tmuxCmd, err := editInMode()
+	if err != nil {
+		return err
+	}
+	if editIn == "pane" || os.Getenv("TMUX") == "" {
+		cmd := exec.Command(args[0], args[1:]...)
 		cmd.Stdin = os.Stdin
 		cmd.Stdout = os.Stdout
 		cmd.Stderr = os.Stderr
-		return cmd.Run()
+		err = cmd.Run()
+	} else {
+		var client tmux.Client
+		if client, err = newTmuxClient(tmuxCmd); err == nil {
+			err = editInTmux(client, editIn, filepath.Base(path), args)
+		}
 	}
-	// macOS fallback: use `open -t` and persist this choice for next time
-	if _, err := exec.LookPath("open"); err == nil {
-		editor = "open -t"
-		if settings, err := cfg.LoadSettings(); err == nil {
-			settings.Editor = editor
-			_ = cfg.SaveSettings(settings)
+	if err != nil {
+		return err
+	}
+	if editor.Detached() && term.IsTerminal(int(os.Stdin.Fd())) {
+		fmt.Fprintf(os.Stderr, "Opened %s with %s; press Enter when you are done editing.", path, args[0])
+		_, _ = bufio.NewReader(os.Stdin).ReadString('\n')
+	}
+	return nil
+}
+
+// newTmuxClient returns the client used to open editors in tmux with the
+// tmux binary bin; tests override it.
+var newTmuxClient = func(bin string) (tmux.Client, error) { return tmux.NewExecClient(bin) }
+
+// editStartTimeout bounds how long the editor's window or popup may take to
+// start its shell, and editPoll how often the shell is checked on.
+var (
+	editStartTimeout = 10 * time.Second
+	editPoll         = time.Second
+)
+
+// editInMode returns the edit_in setting, "pane" if unset, and the tmux
+// binary from the settings.
+func editInMode() (string, string, error) {
+	settings, err := cfg.LoadSettings()
+	if err != nil {
+		return "", "", err
+	}
+	switch mode := strings.TrimSpace(settings.EditIn); mode {
+	case "":
+		return "pane", settings.TmuxCommand, nil
+	case "pane", "window", "popup":
+		return mode, settings.TmuxCommand, nil
+	default:
+		return "", "", fmt.Errorf("invalid edit_in %q in settings (want pane, window or popup)", mode)
+	}
+}
+
+// editInTmux runs the editor command args in a new tmux window or popup
+// named title and blocks until it exits. The editor's shell signals a
+// wait-for channel on exit, however the window or popup is closed; if the
+// shell never starts or dies without signalling, the wait is released and
+// reported as an error.
+func editInTmux(client tmux.Client, mode, title string, args []string) error {
+	dir, err := os.MkdirTemp("", "lmux-edit-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	pidFile, statusFile := filepath.Join(dir, "pid"), filepath.Join(dir, "status")
+
+	channel := fmt.Sprintf("lmux-edit-%d-%d", os.Getpid(), time.Now().UnixNano())
+	script := fmt.Sprintf("echo $$ > %s; trap %s EXIT; trap 'exit 1' HUP INT TERM; %s; echo $? > %s",
+		shell.Quote(pidFile), shell.Quote(shell.Join(tmuxCommand(client, "wait-for", "-S", channel))),
+		shell.Join(args), shell.Quote(statusFile))
+	// Run through sh so the script does not depend on tmux's default-shell
+	shellCmd := shell.Join([]string{"sh", "-c", script})
+
+	if mode == "window" {
+		err = client.Run("new-window", "-n", title, shellCmd)
+	} else {
+		var clientName string
+		if clientName, err = tmux.CurrentClient(client); err == nil {
+			err = client.Run("display-popup", "-E", "-c", clientName, "-w", "80%", "-h", "80%", "-T", " "+title+" ", shellCmd)
 		}
-		cmd := exec.Command("open", "-t", path)
-		return cmd.Run()
 	}
-	return fmt.Errorf("no editor found; set $EDITOR or install 'open'")
+	if err != nil {
+		return err
+	}
+	if err := waitForEditor(client, channel, pidFile); err != nil {
+		return fmt.Errorf("tmux %s for editor %s: %w", mode, args[0], err)
+	}
+	data, err := os.ReadFile(statusFile)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	// The script writes the status last, so no status means the window or
+	// popup was closed before the editor exited
+	switch code := strings.TrimSpace(string(data)); code {
+	case "0":
+		return nil
+	case "":
+		return fmt.Errorf("tmux %s closed before editor %s exited", mode, args[0])
+	default:
+		return fmt.Errorf("editor %s exited with status %s", args[0], code)
+	}
+}
+
+// tmuxCommand returns the command line reaching the server client talks to,
+// for use from a shell inside it: the client's binary with the socket from
+// $TMUX, followed by args.
+func tmuxCommand(client tmux.Client, args ...string) []string {
+	bin := "tmux"
+	if c, ok := client.(*tmux.ExecClient); ok && c.Bin != "" {
+		bin = c.Bin
+	}
+	cmd := []string{bin}
+	if socket, _, _ := strings.Cut(os.Getenv("TMUX"), ","); socket != "" {
+		cmd = append(cmd, "-S", socket)
+	}
+	return append(cmd, args...)
+}
+
+// waitForEditor waits on channel while the editor's shell, whose pid is
+// written to pidFile, is alive. A shell that never starts or exits without
+// signalling has the wait released by signalling the channel here.
+func waitForEditor(client tmux.Client, channel, pidFile string) error {
+	done := make(chan error, 1)
+	go func() { done <- client.Run("wait-for", channel) }()
+	ticker := time.NewTicker(editPoll)
+	defer ticker.Stop()
+	start := time.Now()
+	for {
+		select {
+		case err := <-done:
+			return err
+		case <-ticker.C:
+		}
+		data, _ := os.ReadFile(pidFile)
+		pid, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+		var err error
+		switch {
+		case pid == 0 && time.Since(start) < editStartTimeout, pid != 0 && processAlive(pid):
+			continue
+		case pid == 0:
+			err = fmt.Errorf("the shell did not start within %s", editStartTimeout)
+		}
+		// The shell may have signalled just before exiting
+		select {
+		case err := <-done:
+			return err
+		case <-time.After(editPoll):
+		}
+		if serr := client.Run("wait-for", "-S", channel); serr != nil {
+			return serr
+		}
+		<-done
+		return err
+	}
+}
+
+// processAlive reports whether the process pid is still running.
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	return err == nil && p.Signal(syscall.Signal(0)) == nil
 }
@@ -2,11 +2,14 @@ package util
 
 import (
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	cfg "github.com/sbcinnovation/lmux/internal/config"
+	"github.com/sbcinnovation/lmux/internal/tmux"
 )
 
 func TestOpenInEditorPassesArgumentsWithoutShell(t *testing.T) {
@@ -40,3 +43,202 @@ func TestOpenInEditorPassesArgumentsWithoutShell(t *testing.T) {
 		t.Fatalf("editor arguments = %q, want %q", strings.TrimSpace(got), strings.TrimSpace(want))
 	}
 }
+
+func TestEditorArgsFillsPlaceholders(t *testing.T) {
+	tests := []struct {
+		command string
+		line    int
+		want    string
+	}{
+		{"nvim +{line}", 12, "nvim|+12|/p.toml"},
+		{"nvim +{line}", 0, "nvim|/p.toml"},
+		{"code -w -g {file}:{line}", 3, "code|-w|-g|/p.toml:3"},
+		{"code -w -g {file}:{line}", 0, "code|-w|-g|/p.toml"},
+		{"'my editor' {file} --flag", 0, "my editor|/p.toml|--flag"},
+	}
+	for _, tt := range tests {
+		args, err := Editor{Command: tt.command}.Args("/p.toml", tt.line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Join(args, "|"); got != tt.want {
+			t.Errorf("Args(%q, %d) = %q, want %q", tt.command, tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestResolveEditorPrefersVisualAndDoesNotPersist(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("VISUAL", "nvim")
+	t.Setenv("EDITOR", "nano")
+
+	editor, err := ResolveEditor()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if editor.Command != "nvim" || editor.Source != "env" {
+		t.Fatalf("editor = %+v, want nvim from env", editor)
+	}
+	settings, err := cfg.LoadSettings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings.Editor != "" {
+		t.Fatalf("settings editor = %q, want it left unset", settings.Editor)
+	}
+}
+
+func TestEditorDetached(t *testing.T) {
+	for command, want := range map[string]bool{"xdg-open": true, "open -t": true, "open -W -t": false, "code -w": false} {
+		if got := (Editor{Command: command}).Detached(); got != want {
+			t.Errorf("Detached(%q) = %v, want %v", command, got, want)
+		}
+	}
+}
+
+// scriptClient is a fake tmux that, when waited on, runs the command of the
+// last window or popup it opened, as tmux would have. With lost set, the
+// shell's signal never arrives and the wait lasts until released.
+type scriptClient struct {
+	*tmux.Fake
+	closed bool // the window is closed before its command runs
+	lost   bool
+	script string
+
+	release chan struct{}
+}
+
+func (c *scriptClient) Run(args ...string) error {
+	if err := c.Fake.Run(args...); err != nil {
+		return err
+	}
+	switch {
+	case args[0] == "new-window", args[0] == "display-popup":
+		c.script = args[len(args)-1]
+	case args[0] == "wait-for" && args[1] == "-S":
+		close(c.release)
+	case args[0] == "wait-for":
+		if !c.closed {
+			exec.Command("sh", "-c", c.script).Run()
+		}
+		if c.lost {
+			<-c.release
+		}
+	}
+	return nil
+}
+
+func useScriptClient(t *testing.T, editor, editIn string) *scriptClient {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	// Keep the script's wait-for signal away from any real tmux server
+	t.Setenv("TMUX_TMPDIR", home)
+	t.Setenv("TMUX", filepath.Join(home, "default")+",1,0")
+	if err := cfg.SaveSettings(cfg.Settings{Editor: editor, EditIn: editIn}); err != nil {
+		t.Fatal(err)
+	}
+	client := &scriptClient{Fake: tmux.NewFake(), release: make(chan struct{})}
+	original := newTmuxClient
+	newTmuxClient = func(string) (tmux.Client, error) { return client, nil }
+	t.Cleanup(func() { newTmuxClient = original })
+	return client
+}
+
+func TestOpenInEditorUsesTmuxWindowAndWaits(t *testing.T) {
+	client := useScriptClient(t, "true +{line}", "window")
+
+	if err := OpenInEditorAt("/p/api.toml", 7); err != nil {
+		t.Fatal(err)
+	}
+	cmds := client.Commands()
+	if len(cmds) != 2 {
+		t.Fatalf("tmux commands = %q, want new-window then wait-for", cmds)
+	}
+	if !strings.HasPrefix(cmds[0], "new-window -n api.toml ") || !strings.Contains(cmds[0], "true +7 /p/api.toml") {
+		t.Fatalf("new-window command = %q", cmds[0])
+	}
+	channel := strings.TrimPrefix(cmds[1], "wait-for ")
+	if !strings.HasPrefix(channel, "lmux-edit-") || !strings.Contains(cmds[0], "wait-for -S "+channel) {
+		t.Fatalf("wait-for command = %q does not match the window's signal in %q", cmds[1], cmds[0])
+	}
+	socket, _, _ := strings.Cut(os.Getenv("TMUX"), ",")
+	if !strings.Contains(cmds[0], "tmux -S "+socket+" wait-for") {
+		t.Fatalf("new-window command = %q, want the signal sent to the socket in $TMUX", cmds[0])
+	}
+}
+
+func TestOpenInEditorUsesTmuxPopupOnCurrentClient(t *testing.T) {
+	client := useScriptClient(t, "true", "popup")
+	client.Outputs = map[string]string{"display-message": "/dev/pts/3\n"}
+
+	if err := OpenInEditor("/p/api.toml"); err != nil {
+		t.Fatal(err)
+	}
+	cmds := client.Commands()
+	if len(cmds) != 3 {
+		t.Fatalf("tmux commands = %q, want display-message, display-popup then wait-for", cmds)
+	}
+	if want := "display-popup -E -c /dev/pts/3 -w 80% -h 80% -T  api.toml  "; !strings.HasPrefix(cmds[1], want) {
+		t.Fatalf("popup command = %q, want prefix %q", cmds[1], want)
+	}
+	if !strings.Contains(cmds[1], "true /p/api.toml") {
+		t.Fatalf("popup command = %q does not run the editor", cmds[1])
+	}
+}
+
+func TestOpenInEditorInTmuxReportsFailedEditor(t *testing.T) {
+	useScriptClient(t, "false", "window")
+	err := OpenInEditor("/p/api.toml")
+	if err == nil || !strings.Contains(err.Error(), "exited with status 1") {
+		t.Fatalf("err = %v, want the editor's exit status", err)
+	}
+}
+
+func TestOpenInEditorInTmuxReportsClosedWindow(t *testing.T) {
+	client := useScriptClient(t, "true", "popup")
+	client.Outputs = map[string]string{"display-message": "/dev/pts/3\n"}
+	client.closed = true
+	err := OpenInEditor("/p/api.toml")
+	if err == nil || !strings.Contains(err.Error(), "closed before editor true exited") {
+		t.Fatalf("err = %v, want the popup reported as closed", err)
+	}
+}
+
+func TestOpenInEditorInTmuxGivesUpOnShellThatNeverStarts(t *testing.T) {
+	client := useScriptClient(t, "true", "window")
+	client.closed, client.lost = true, true
+	useEditTimings(t, 50*time.Millisecond)
+
+	err := OpenInEditor("/p/api.toml")
+	if err == nil || !strings.Contains(err.Error(), "did not start") {
+		t.Fatalf("err = %v, want the wait given up", err)
+	}
+}
+
+func TestOpenInEditorInTmuxNoticesShellKilledBeforeSignalling(t *testing.T) {
+	client := useScriptClient(t, `sh -c "kill -KILL $PPID"`, "window")
+	client.lost = true
+	useEditTimings(t, time.Second)
+
+	err := OpenInEditor("/p/api.toml")
+	if err == nil || !strings.Contains(err.Error(), "closed before editor sh exited") {
+		t.Fatalf("err = %v, want the killed window reported", err)
+	}
+}
+
+func useEditTimings(t *testing.T, start time.Duration) {
+	t.Helper()
+	originalStart, originalPoll := editStartTimeout, editPoll
+	editStartTimeout, editPoll = start, 10*time.Millisecond
+	t.Cleanup(func() { editStartTimeout, editPoll = originalStart, originalPoll })
+}
+
+func TestTmuxCommandUsesClientBinaryAndSocket(t *testing.T) {
+	t.Setenv("TMUX", "/tmp/lmux-test/work,123,0")
+	got := tmuxCommand(&tmux.ExecClient{Bin: "/opt/tmux/bin/tmux"}, "wait-for", "-S", "ch")
+	if want := "/opt/tmux/bin/tmux -S /tmp/lmux-test/work wait-for -S ch"; strings.Join(got, " ") != want {
+		t.Fatalf("tmuxCommand() = %q, want %q", got, want)
+	}
+}